@@ -0,0 +1,379 @@
+// Command selftest checks that a running pipeline is wired end to end. It
+// publishes a synthetic trade for the active symbol to trades.raw, waits
+// for processing to publish it on trades.processed, then checks that the
+// API serves it on /api/price and pushes it to /ws clients. It prints a
+// report of every stage and exits non-zero if any failed within -timeout.
+//
+// The trade uses the symbol's latest price and time, so it shifts the
+// live stats as little as possible; it's still stored like any other.
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nats-io/nats.go"
+
+	"shared/msgcodec"
+	"shared/natsconn"
+)
+
+// republishInterval is how long to wait for processing before publishing
+// again: a trade can be dropped as out of order if a newer one for the
+// symbol got there first
+const republishInterval = 2 * time.Second
+
+// TradeMessage is what ingestion publishes on trades.raw
+type TradeMessage struct {
+	Symbol  string  `json:"symbol"`
+	Price   float64 `json:"price"`
+	Time    int64   `json:"time"`
+	TraceID string  `json:"trace_id,omitempty"`
+}
+
+// ProcessedMessage is the part of processing's trades.processed message
+// the test follows
+type ProcessedMessage struct {
+	Symbol  string  `json:"symbol"`
+	Price   float64 `json:"price"`
+	Time    int64   `json:"time"`
+	TraceID string  `json:"trace_id,omitempty"`
+}
+
+// stage is one line of the report
+type stage struct {
+	name   string
+	err    error
+	detail string
+	took   time.Duration
+	ran    bool
+}
+
+type selftest struct {
+	api      string
+	natsURL  string
+	deadline time.Time
+	client   *http.Client
+
+	symbol string
+	price  float64
+	time   int64
+
+	nc        *nats.Conn
+	processed chan ProcessedMessage
+	ws        *websocket.Conn
+	wsPrices  chan float64
+
+	mu      sync.Mutex
+	newest  int64           // newest trade time seen on trades.processed for symbol
+	traceID map[string]bool // trace IDs of the trades published so far
+	result  ProcessedMessage
+}
+
+func main() {
+	natsDefault := os.Getenv("NATS_URL")
+	if natsDefault == "" {
+		natsDefault = nats.DefaultURL
+	}
+	api := flag.String("api", "http://localhost:8080", "API base URL, including any BASE_PATH")
+	natsURL := flag.String("nats", natsDefault, "NATS URL (default NATS_URL)")
+	timeout := flag.Duration("timeout", 15*time.Second, "time allowed for the whole test")
+	flag.Parse()
+
+	t := &selftest{
+		api:       strings.TrimSuffix(*api, "/"),
+		natsURL:   *natsURL,
+		deadline:  time.Now().Add(*timeout),
+		client:    &http.Client{Timeout: 5 * time.Second},
+		processed: make(chan ProcessedMessage, 1),
+		wsPrices:  make(chan float64, 1024),
+		traceID:   make(map[string]bool),
+	}
+
+	stages := []*stage{
+		{name: "api: active symbol and price"},
+		{name: "nats: connect"},
+		{name: "ws: connect"},
+		{name: "processing: trades.raw -> trades.processed"},
+		{name: "api: /api/price"},
+		{name: "api: /ws"},
+	}
+	steps := []func() (string, error){
+		t.checkAPI,
+		t.connectNATS,
+		t.connectWS,
+		t.checkProcessing,
+		t.checkPrice,
+		t.checkWS,
+	}
+
+	failed := false
+	for i, st := range stages {
+		if failed {
+			break
+		}
+		start := time.Now()
+		st.detail, st.err = steps[i]()
+		st.took = time.Since(start)
+		st.ran = true
+		failed = st.err != nil
+	}
+	if t.nc != nil {
+		t.nc.Close()
+	}
+	if t.ws != nil {
+		t.ws.Close()
+	}
+
+	fmt.Printf("Pipeline self-test against %s and %s\n\n", t.api, t.natsURL)
+	for _, st := range stages {
+		switch {
+		case !st.ran:
+			fmt.Printf("  SKIP  %s\n", st.name)
+		case st.err != nil:
+			fmt.Printf("  FAIL  %s (%s): %v\n", st.name, st.took.Round(time.Millisecond), st.err)
+		default:
+			fmt.Printf("  ok    %s (%s) %s\n", st.name, st.took.Round(time.Millisecond), st.detail)
+		}
+	}
+	fmt.Println()
+	if failed {
+		fmt.Println("FAIL")
+		os.Exit(1)
+	}
+	fmt.Println("PASS")
+}
+
+// checkAPI reads the active symbol and its latest price, which the
+// synthetic trade copies
+func (t *selftest) checkAPI() (string, error) {
+	var sym struct {
+		Symbol string `json:"symbol"`
+	}
+	if err := t.getJSON("/api/symbol", &sym); err != nil {
+		return "", err
+	}
+	if sym.Symbol == "" {
+		return "", errors.New("/api/symbol returned no symbol")
+	}
+	t.symbol = sym.Symbol
+
+	var price struct {
+		Price float64 `json:"price"`
+		Time  int64   `json:"time"`
+	}
+	if err := t.getJSON("/api/price", &price); err != nil {
+		return "", err
+	}
+	t.price, t.time = price.Price, price.Time
+	if t.price <= 0 {
+		// Nothing processed yet; any positive price will do
+		t.price = 1
+	}
+	t.newest = t.time
+	return fmt.Sprintf("%s at %g", t.symbol, t.price), nil
+}
+
+func (t *selftest) connectNATS() (string, error) {
+	opts := append(natsconn.Options(), nats.Timeout(time.Until(t.deadline)))
+	nc, err := nats.Connect(t.natsURL, opts...)
+	if err != nil {
+		return "", err
+	}
+	t.nc = nc
+
+	// Follow processed trades for the symbol: the newest time, so the
+	// synthetic trade isn't out of order, and our own trade coming back
+	_, err = nc.Subscribe("trades.processed", func(msg *nats.Msg) {
+		var p ProcessedMessage
+		if err := msgcodec.Decode(msg, &p); err != nil || p.Symbol != t.symbol {
+			return
+		}
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if p.Time > t.newest {
+			t.newest = p.Time
+		}
+		if t.traceID[p.TraceID] && t.result.TraceID == "" {
+			t.result = p
+			t.processed <- p
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	return nc.ConnectedUrl(), nc.Flush()
+}
+
+// connectWS opens /ws before the trade is published, so its price can't
+// be missed
+func (t *selftest) connectWS() (string, error) {
+	u, err := url.Parse(t.api + "/ws")
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+	dialer := websocket.Dialer{HandshakeTimeout: time.Until(t.deadline)}
+	conn, _, err := dialer.Dial(u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	t.ws = conn
+
+	go func() {
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				close(t.wsPrices)
+				return
+			}
+			var msg struct {
+				Type string `json:"type"`
+				Data struct {
+					Price float64 `json:"price"`
+				} `json:"data"`
+			}
+			if json.Unmarshal(data, &msg) != nil || msg.Type != "price" {
+				continue
+			}
+			select {
+			case t.wsPrices <- msg.Data.Price:
+			default:
+			}
+		}
+	}()
+	return u.String(), nil
+}
+
+// checkProcessing publishes the synthetic trade, again every
+// republishInterval, until processing publishes one of them
+func (t *selftest) checkProcessing() (string, error) {
+	published := 0
+	for {
+		traceID := newTraceID()
+		t.mu.Lock()
+		t.traceID[traceID] = true
+		trade := TradeMessage{Symbol: t.symbol, Price: t.price, Time: t.newest, TraceID: traceID}
+		t.mu.Unlock()
+		if trade.Time == 0 {
+			trade.Time = time.Now().UnixMilli()
+		}
+
+		data, _ := json.Marshal(trade)
+		if err := t.nc.Publish("trades.raw", data); err != nil {
+			return "", err
+		}
+		published++
+
+		wait := min(republishInterval, time.Until(t.deadline))
+		select {
+		case p := <-t.processed:
+			return fmt.Sprintf("trace %s, price %g", p.TraceID, p.Price), nil
+		case <-time.After(wait):
+		}
+		if time.Now().After(t.deadline) {
+			return "", fmt.Errorf("none of %d synthetic trades came back on trades.processed; is processing running, on %s, and not withholding unwarmed trades?", published, t.symbol)
+		}
+	}
+}
+
+// checkPrice polls /api/price for the processed trade. On a busy symbol a
+// newer trade can replace it between polls, so once /api/price has moved
+// past it, /api/recent has to contain it instead.
+func (t *selftest) checkPrice() (string, error) {
+	want := t.result
+	for {
+		var got struct {
+			Price float64 `json:"price"`
+			Time  int64   `json:"time"`
+		}
+		if err := t.getJSON("/api/price", &got); err != nil {
+			return "", err
+		}
+		if got.Price == want.Price && got.Time == want.Time {
+			return fmt.Sprintf("price %g", got.Price), nil
+		}
+		if got.Time > want.Time {
+			var recent struct {
+				Prices []struct {
+					Price float64 `json:"price"`
+					Time  int64   `json:"time"`
+				} `json:"prices"`
+			}
+			if err := t.getJSON("/api/recent?symbol="+url.QueryEscape(t.symbol), &recent); err != nil {
+				return "", err
+			}
+			for _, p := range recent.Prices {
+				if p.Price == want.Price && p.Time == want.Time {
+					return fmt.Sprintf("price %g (already replaced, found in /api/recent)", p.Price), nil
+				}
+			}
+			return "", fmt.Errorf("/api/price moved past the synthetic trade (time %d) without showing it, and /api/recent doesn't have it", want.Time)
+		}
+		if time.Now().After(t.deadline) {
+			return "", fmt.Errorf("/api/price still shows %g at %d, want %g at %d", got.Price, got.Time, want.Price, want.Time)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// checkWS waits for the processed price among the /ws price messages
+func (t *selftest) checkWS() (string, error) {
+	timeout := time.After(time.Until(t.deadline))
+	seen := 0
+	for {
+		select {
+		case price, ok := <-t.wsPrices:
+			if !ok {
+				return "", errors.New("connection closed before the price arrived")
+			}
+			seen++
+			if price == t.result.Price {
+				return fmt.Sprintf("price %g after %d messages", price, seen), nil
+			}
+		case <-timeout:
+			return "", fmt.Errorf("price %g not received (%d other price messages)", t.result.Price, seen)
+		}
+	}
+}
+
+func (t *selftest) getJSON(path string, v any) error {
+	resp, err := t.client.Get(t.api + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && strings.HasPrefix(path, "/api/price") {
+		// No trade processed for the symbol yet
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("GET %s: %w", path, err)
+	}
+	return nil
+}
+
+func newTraceID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "selftest-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// requireAdmin guards a handler with ADMIN_TOKEN, sent as a bearer token.
+// With no token configured, admin endpoints are disabled.
+func requireAdmin(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			writeJSONError(w, http.StatusNotFound, "Admin endpoints disabled (set ADMIN_TOKEN)")
+			return
+		}
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSONError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleAdminReset asks processing to clear its indicator state without
+// changing symbol
+func (s *Server) handleAdminReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+
+	if err := s.nc.Publish("control.reset", []byte("{}")); err != nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "Failed to publish reset")
+		return
+	}
+
+	s.mu.RLock()
+	symbol := s.symbol
+	s.mu.RUnlock()
+	log.Printf("Processor reset requested for %s", symbol)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reset":  true,
+		"symbol": symbol,
+	})
+}
+
+// handleAdminClients lists connected WebSocket clients, with the symbol
+// each subscribed to, or the active symbol for those that haven't
+func (s *Server) handleAdminClients(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	symbols := []string{s.symbol}
+	s.mu.RUnlock()
+
+	clients := s.ws.snapshot(symbols)
+
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].ConnectedAt.Before(clients[j].ConnectedAt)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count":   len(clients),
+		"clients": clients,
+	})
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Bounds for a backtest request
+const (
+	defaultBacktestRange = 24 * time.Hour
+	maxBacktestRange     = 7 * 24 * time.Hour
+	maxBacktestWindow    = 10000
+	maxBacktestTicks     = 5_000_000
+)
+
+// Backtest rules. Both trade one unit, long only, on the price crossing its
+// moving average.
+const (
+	ruleMeanReversion = "mean_reversion" // buy crossing below the MA, sell crossing above
+	ruleMomentum      = "momentum"       // buy crossing above the MA, sell crossing below
+)
+
+type backtestRequest struct {
+	Symbol string    `json:"symbol"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	Rule   string    `json:"rule"`
+	Window int       `json:"window"` // moving average length in ticks
+}
+
+// backtestResult summarises a run. P&L is in quote currency per unit held;
+// a position still open at the end is marked to the last price.
+type backtestResult struct {
+	Symbol       string    `json:"symbol"`
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	Rule         string    `json:"rule"`
+	Window       int       `json:"window"`
+	Ticks        int       `json:"ticks"`
+	Trades       int       `json:"trades"` // completed round trips
+	Wins         int       `json:"wins"`
+	PnL          float64   `json:"pnl"`
+	MaxDrawdown  float64   `json:"max_drawdown"` // largest fall from an equity peak
+	OpenPosition bool      `json:"open_position"`
+}
+
+// backtester replays ticks through a moving-average crossover rule
+type backtester struct {
+	rule   string
+	window []float64
+	next   int
+	filled bool
+	sum    float64
+
+	prevSide int // -1 below the MA, 1 above, 0 unknown or on it
+	long     bool
+	entry    float64
+	last     float64
+
+	realized float64
+	peak     float64
+	res      backtestResult
+}
+
+func newBacktester(rule string, window int) *backtester {
+	return &backtester{rule: rule, window: make([]float64, window)}
+}
+
+// step feeds one tick: the crossing is judged against the MA of the ticks
+// before it, so a tick never trades on an average it's part of
+func (b *backtester) step(price float64) {
+	b.res.Ticks++
+	b.last = price
+
+	if b.filled {
+		ma := b.sum / float64(len(b.window))
+		side := 0
+		if price < ma {
+			side = -1
+		} else if price > ma {
+			side = 1
+		}
+		if side != 0 && b.prevSide != 0 && side != b.prevSide {
+			buy := side == -1
+			if b.rule == ruleMomentum {
+				buy = !buy
+			}
+			if buy && !b.long {
+				b.long, b.entry = true, price
+			} else if !buy && b.long {
+				b.long = false
+				b.realized += price - b.entry
+				b.res.Trades++
+				if price > b.entry {
+					b.res.Wins++
+				}
+			}
+		}
+		if side != 0 {
+			b.prevSide = side
+		}
+	}
+
+	b.sum += price - b.window[b.next]
+	b.window[b.next] = price
+	b.next++
+	if b.next == len(b.window) {
+		b.next = 0
+		b.filled = true
+	}
+
+	equity := b.equity()
+	b.peak = max(b.peak, equity)
+	b.res.MaxDrawdown = max(b.res.MaxDrawdown, b.peak-equity)
+}
+
+func (b *backtester) equity() float64 {
+	if b.long {
+		return b.realized + b.last - b.entry
+	}
+	return b.realized
+}
+
+func (b *backtester) result() backtestResult {
+	res := b.res
+	res.PnL = b.equity()
+	res.OpenPosition = b.long
+	return res
+}
+
+// handleBacktest replays stored trades for a symbol and time range through
+// a moving-average crossover rule and returns the resulting P&L, trade
+// count and max drawdown
+func (s *Server) handleBacktest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+	if s.db == nil {
+		writeJSONErrorCode(w, http.StatusServiceUnavailable, codeDBUnavailable, "Database not available")
+		return
+	}
+
+	var req backtestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if req.Symbol == "" {
+		s.mu.RLock()
+		req.Symbol = s.symbol
+		s.mu.RUnlock()
+	}
+	switch req.Rule {
+	case "":
+		req.Rule = ruleMeanReversion
+	case ruleMeanReversion, ruleMomentum:
+	default:
+		writeJSONError(w, http.StatusBadRequest, "Unknown rule (mean_reversion or momentum)")
+		return
+	}
+	if req.Window == 0 {
+		req.Window = 20
+	}
+	if req.Window < 2 || req.Window > maxBacktestWindow {
+		writeJSONError(w, http.StatusBadRequest, "Invalid window (2-10000 ticks)")
+		return
+	}
+	if req.To.IsZero() {
+		req.To = s.clock.Now()
+	}
+	if req.From.IsZero() {
+		req.From = req.To.Add(-defaultBacktestRange)
+	}
+	if !req.From.Before(req.To) || req.To.Sub(req.From) > maxBacktestRange {
+		writeJSONError(w, http.StatusBadRequest, "Invalid range (from must be before to, at most 168h apart)")
+		return
+	}
+
+	rows, err := s.db.Query(r.Context(), `
+		SELECT price FROM trades
+		WHERE symbol = $1 AND time >= $2 AND time < $3
+		ORDER BY `+s.timeOrder(`ASC`),
+		req.Symbol, req.From, req.To)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to fetch trades")
+		return
+	}
+	defer rows.Close()
+
+	bt := newBacktester(req.Rule, req.Window)
+	for rows.Next() {
+		var price float64
+		if err := rows.Scan(&price); err != nil {
+			continue
+		}
+		if bt.res.Ticks == maxBacktestTicks {
+			writeJSONError(w, http.StatusBadRequest, "Too many trades in range, narrow it")
+			return
+		}
+		bt.step(price)
+	}
+	if rows.Err() != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to fetch trades")
+		return
+	}
+
+	res := bt.result()
+	res.Symbol, res.Rule, res.Window = req.Symbol, req.Rule, req.Window
+	res.From, res.To = req.From.UTC(), req.To.UTC()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// BookMessage from ingestion service (best bid/ask)
+type BookMessage struct {
+	Symbol string  `json:"symbol"`
+	Bid    float64 `json:"bid"`
+	Ask    float64 `json:"ask"`
+	Time   int64   `json:"time"`
+}
+
+func (s *Server) handleBook(w http.ResponseWriter, r *http.Request) {
+	symbol := r.URL.Query().Get("symbol")
+	if symbol == "" {
+		s.mu.RLock()
+		symbol = s.symbol
+		s.mu.RUnlock()
+	}
+
+	s.booksMu.RLock()
+	book, ok := s.books[symbol]
+	s.booksMu.RUnlock()
+	if !ok {
+		writeJSONErrorCode(w, http.StatusNotFound, codeNoData, "No book data for symbol")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"symbol": book.Symbol,
+		"bid":    book.Bid,
+		"ask":    book.Ask,
+		"spread": book.Ask - book.Bid,
+		"time":   book.Time,
+	})
+}
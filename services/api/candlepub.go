@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// candleCloseGrace is how long after its period ends a candle is
+// published, so trades from just before the close that are still on their
+// way make it in
+const candleCloseGrace = 2 * time.Second
+
+// candlePublisher publishes each completed live candle on NATS as
+// candles.<interval>.<symbol> (e.g. candles.1m.btcusdt), for the intervals
+// in CANDLE_PUBLISH_INTERVALS. Consumers get bars pushed as they close
+// instead of polling /api/candles. A nil publisher publishes nothing.
+type candlePublisher struct {
+	nc        *nats.Conn
+	intervals map[string]bool // by label
+}
+
+// parseCandleIntervals parses a comma-separated list of ohlcIntervals
+// (e.g. "1m,1h"); empty means none
+func parseCandleIntervals(v string) (map[string]bool, error) {
+	set := make(map[string]bool)
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		d, err := time.ParseDuration(part)
+		if err != nil || !validOHLCInterval(d) {
+			return nil, fmt.Errorf("unknown interval %q (1m, 5m, 15m, 1h, 4h or 24h)", part)
+		}
+		set[intervalLabel(d)] = true
+	}
+	return set, nil
+}
+
+func newCandlePublisher(nc *nats.Conn, intervals map[string]bool) *candlePublisher {
+	if len(intervals) == 0 {
+		return nil
+	}
+	return &candlePublisher{nc: nc, intervals: intervals}
+}
+
+// publish sends each of bars whose interval is published, as the
+// /api/ohlc/latest body with closed true
+func (p *candlePublisher) publish(bars []liveCandle) {
+	if p == nil {
+		return
+	}
+	for _, bar := range bars {
+		if !p.intervals[bar.Interval] {
+			continue
+		}
+		data, err := json.Marshal(bar)
+		if err != nil {
+			continue
+		}
+		p.nc.Publish("candles."+bar.Interval+"."+bar.Symbol, data)
+		metrics.Add("candles_published", 1)
+	}
+}
+
+// publishClosedCandles publishes candles once their period (plus
+// candleCloseGrace) is over. A trade starting the next period publishes
+// the previous candle sooner; this covers symbols that have gone quiet.
+func (s *Server) publishClosedCandles() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.candles.publish(s.ohlc.closeDue(s.clock.Now().Add(-candleCloseGrace)))
+	}
+}
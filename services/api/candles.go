@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Bounds for candle buckets; any time.Duration in between is accepted
+const (
+	minCandleInterval = time.Second
+	maxCandleInterval = 7 * 24 * time.Hour
+	maxCandles        = 1000
+)
+
+// Candle is one OHLC bucket of stored trades
+type Candle struct {
+	Time   time.Time `json:"time"`
+	Open   float64   `json:"open"`
+	High   float64   `json:"high"`
+	Low    float64   `json:"low"`
+	Close  float64   `json:"close"`
+	Volume float64   `json:"volume,omitempty"` // base-asset quantity; only live candles have it
+	Trades int       `json:"trades"`
+}
+
+// handleCandles resamples stored trades into OHLC buckets of any size
+// (?interval=15s, 90m, ...), covering the last `limit` buckets. Buckets
+// are aligned to TIMEZONE's wall clock and their times given in it.
+func (s *Server) handleCandles(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSONErrorCode(w, http.StatusServiceUnavailable, codeDBUnavailable, "Database not available")
+		return
+	}
+
+	q := r.URL.Query()
+	symbol := q.Get("symbol")
+	if symbol == "" {
+		s.mu.RLock()
+		symbol = s.symbol
+		s.mu.RUnlock()
+	}
+
+	interval := time.Minute
+	if v := q.Get("interval"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid interval (a positive duration like 15s or 90m)")
+			return
+		}
+		if d < minCandleInterval || d > maxCandleInterval {
+			writeJSONError(w, http.StatusBadRequest, "Interval out of range (1s to 168h)")
+			return
+		}
+		interval = d
+	}
+
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = min(n, maxCandles)
+	}
+
+	// time_bucket's timezone form needs TimescaleDB 2.8, so UTC keeps the
+	// plain one
+	bucket := `time_bucket($1::interval, time)`
+	args := []any{interval, symbol, limit}
+	if s.loc != time.UTC {
+		bucket = `time_bucket($1::interval, time, $4)`
+		args = append(args, s.loc.String())
+	}
+	rows, err := s.db.Query(r.Context(), `
+		SELECT `+bucket+` AS bucket,
+			first(price, time), max(price), min(price), last(price, time), count(*)
+		FROM trades
+		WHERE symbol = $2 AND time > now() - $1::interval * $3
+		GROUP BY bucket
+		ORDER BY bucket ASC`,
+		args...)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to fetch candles")
+		return
+	}
+	defer rows.Close()
+
+	candles := []Candle{}
+	for rows.Next() {
+		var c Candle
+		if err := rows.Scan(&c.Time, &c.Open, &c.High, &c.Low, &c.Close, &c.Trades); err != nil {
+			continue
+		}
+		c.Time = c.Time.In(s.loc)
+		candles = append(candles, c)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"symbol":   symbol,
+		"interval": interval.String(),
+		"candles":  candles,
+	})
+}
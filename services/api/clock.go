@@ -0,0 +1,15 @@
+package main
+
+import "time"
+
+// Clock is the source of wall-clock time for anything that schedules or
+// ages on it (trade log rotation, status staleness), so it can be pinned
+// and stepped instead of waiting on time.Now
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is the system clock
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestAppendJSONFloat(t *testing.T) {
+	floats := []float64{0, math.Copysign(0, -1), 1, -1, 0.1, 42000.12, 1e-6, 9.99e-7, 1e-7, 1e20, 1e21, 1.5e300, -2.5e-300, math.MaxFloat64, math.SmallestNonzeroFloat64}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 50000; i++ {
+		floats = append(floats, math.Float64frombits(rng.Uint64()))
+	}
+
+	for _, f := range floats {
+		got := string(appendJSONFloat(nil, f))
+		want, err := json.Marshal(f)
+		if err != nil {
+			// NaN and Inf have no JSON form
+			if got != "null" {
+				t.Errorf("appendJSONFloat(%v) = %s, want null", f, got)
+			}
+			continue
+		}
+		if got != string(want) {
+			t.Errorf("appendJSONFloat(%v) = %s, want %s", f, got, want)
+		}
+	}
+}
+
+func BenchmarkFloatMarshal(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(42000.12); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAppendJSONFloat(b *testing.B) {
+	buf := make([]byte, 0, 32)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = appendJSONFloat(buf[:0], 42000.12)
+	}
+}
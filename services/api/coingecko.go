@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// coinGeckoRetry is how soon a failed CoinGecko fetch is retried, when
+// that's sooner than the TTL
+const coinGeckoRetry = time.Minute
+
+// coinMeta is what CoinGecko knows about a base asset
+type coinMeta struct {
+	Name      string  // e.g. "Bitcoin"
+	MarketCap float64 // USD
+}
+
+// coinEnricher fills in real names and market caps for the coins from
+// CoinGecko's public markets API (COINGECKO_ENRICH). It fetches every
+// coin's base asset in one request at startup and again every ttl. A failed
+// fetch keeps what the last good one got, and coins CoinGecko doesn't know
+// keep the name they have. A nil enricher (the default) knows nothing.
+type coinEnricher struct {
+	url    string
+	ttl    time.Duration
+	client *http.Client
+
+	mu   sync.RWMutex
+	meta map[string]coinMeta // by lowercase base asset
+}
+
+func newCoinEnricher(baseURL string, ttl time.Duration) *coinEnricher {
+	return &coinEnricher{
+		url:    strings.TrimSuffix(baseURL, "/"),
+		ttl:    ttl,
+		client: &http.Client{Timeout: 10 * time.Second},
+		meta:   make(map[string]coinMeta),
+	}
+}
+
+// run refreshes the metadata for bases until ctx is cancelled
+func (e *coinEnricher) run(ctx context.Context, bases []string) {
+	for {
+		wait := e.ttl
+		if err := e.refresh(ctx, bases); err != nil {
+			log.Printf("CoinGecko fetch failed, keeping current names: %v", err)
+			wait = min(wait, coinGeckoRetry)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// coinGeckoMarket is one entry of the /coins/markets response
+type coinGeckoMarket struct {
+	Symbol    string  `json:"symbol"`
+	Name      string  `json:"name"`
+	MarketCap float64 `json:"market_cap"`
+}
+
+func (e *coinEnricher) refresh(ctx context.Context, bases []string) error {
+	q := url.Values{}
+	q.Set("vs_currency", "usd")
+	q.Set("symbols", strings.Join(bases, ","))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.url+"/coins/markets?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("coins/markets: %s", resp.Status)
+	}
+
+	var markets []coinGeckoMarket
+	if err := json.NewDecoder(resp.Body).Decode(&markets); err != nil {
+		return fmt.Errorf("coins/markets: %w", err)
+	}
+
+	// Several tokens can share a ticker; the largest is the one meant
+	meta := make(map[string]coinMeta, len(markets))
+	for _, m := range markets {
+		base := strings.ToLower(m.Symbol)
+		if m.Name == "" {
+			continue
+		}
+		if prev, ok := meta[base]; ok && prev.MarketCap >= m.MarketCap {
+			continue
+		}
+		meta[base] = coinMeta{Name: m.Name, MarketCap: m.MarketCap}
+	}
+
+	e.mu.Lock()
+	for base, m := range meta {
+		e.meta[base] = m
+	}
+	e.mu.Unlock()
+	log.Printf("CoinGecko metadata for %d of %d assets", len(meta), len(bases))
+	return nil
+}
+
+func (e *coinEnricher) lookup(base string) (coinMeta, bool) {
+	if e == nil {
+		return coinMeta{}, false
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	m, ok := e.meta[base]
+	return m, ok
+}
+
+// enrichedName is name, or for a coin whose name was only derived from its
+// symbol, CoinGecko's name for its base in the built-in style: "Bitcoin
+// (BTC)" for USDT pairs, "Bitcoin (BTC/USDC)" otherwise
+func (s *Server) enrichedName(symbol, name string) string {
+	c, ok := findCoin(symbol)
+	if !ok || !c.nameDerived {
+		return name
+	}
+	m, ok := s.coinMeta.lookup(c.Base)
+	if !ok {
+		return name
+	}
+	pair := strings.ToUpper(c.Base)
+	if c.Quote != "usdt" {
+		pair += "/" + strings.ToUpper(c.Quote)
+	}
+	return m.Name + " (" + pair + ")"
+}
+
+// coinListing is a coin as /api/coins lists it
+type coinListing struct {
+	Coin
+	MarketCap float64 `json:"market_cap,omitempty"` // USD, from CoinGecko
+}
+
+// coinListings is the coin list with CoinGecko names and market caps
+// applied, when enrichment is on
+func (s *Server) coinListings() []coinListing {
+	out := make([]coinListing, len(coins))
+	for i, c := range coins {
+		out[i] = coinListing{Coin: c}
+		out[i].Name = s.enrichedName(c.Symbol, c.Name)
+		if m, ok := s.coinMeta.lookup(c.Base); ok && !c.index {
+			out[i].MarketCap = m.MarketCap
+		}
+	}
+	return out
+}
+
+// coinBases lists the base assets to look up: every coin's but the
+// indexes', once each
+func coinBases() []string {
+	var bases []string
+	seen := make(map[string]bool)
+	for _, c := range coins {
+		if c.index || c.Base == "" || seen[c.Base] {
+			continue
+		}
+		seen[c.Base] = true
+		bases = append(bases, c.Base)
+	}
+	return bases
+}
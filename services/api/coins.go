@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"shared/indexdef"
+)
+
+// Coin is a tradable pair. Quote is the asset prices are denominated in.
+// Precision is the number of decimals to display; 0 lets clients derive it
+// from the price's magnitude. Market is "spot" (empty means spot) or
+// "futures" for USDT-margined futures, which ingestion streams from the
+// futures endpoints. TickSize, when set, is the increment processing rounds
+// published prices to.
+type Coin struct {
+	Symbol    string  `json:"symbol"`
+	Name      string  `json:"name"`
+	Base      string  `json:"base"`
+	Quote     string  `json:"quote"`
+	Precision int     `json:"precision"`
+	Market    string  `json:"market,omitempty"`
+	TickSize  float64 `json:"tick_size,omitempty"`
+
+	nameDerived bool // Name was made up from the symbol, not given
+	index       bool // an INDEXES basket rather than a traded pair
+}
+
+var coins = []Coin{
+	{Symbol: "btcusdt", Name: "Bitcoin (BTC)", Base: "btc", Quote: "usdt", Precision: 2},
+	{Symbol: "ethusdt", Name: "Ethereum (ETH)", Base: "eth", Quote: "usdt", Precision: 2},
+	{Symbol: "solusdt", Name: "Solana (SOL)", Base: "sol", Quote: "usdt", Precision: 3},
+	{Symbol: "bnbusdt", Name: "Binance Coin (BNB)", Base: "bnb", Quote: "usdt", Precision: 2},
+	{Symbol: "xrpusdt", Name: "Ripple (XRP)", Base: "xrp", Quote: "usdt", Precision: 4},
+	{Symbol: "dogeusdt", Name: "Dogecoin (DOGE)", Base: "doge", Quote: "usdt", Precision: 6},
+	{Symbol: "btcusdc", Name: "Bitcoin (BTC/USDC)", Base: "btc", Quote: "usdc", Precision: 2},
+	{Symbol: "ethbtc", Name: "Ethereum (ETH/BTC)", Base: "eth", Quote: "btc", Precision: 6},
+}
+
+// loadCoins replaces the built-in coin list with a JSON array from path
+func loadCoins(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var list []Coin
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		return errors.New("no coins defined")
+	}
+
+	for i := range list {
+		c := &list[i]
+		c.Symbol = strings.ToLower(c.Symbol)
+		c.Base = strings.ToLower(c.Base)
+		c.Quote = strings.ToLower(c.Quote)
+		if c.Symbol == "" || c.Quote == "" {
+			return fmt.Errorf("coin %d: symbol and quote are required", i)
+		}
+		if c.Precision < 0 || c.Precision > 12 {
+			return fmt.Errorf("coin %s: precision must be 0-12", c.Symbol)
+		}
+		if c.Market != "" && c.Market != "spot" && c.Market != "futures" {
+			return fmt.Errorf("coin %s: market must be spot or futures", c.Symbol)
+		}
+		if c.TickSize < 0 {
+			return fmt.Errorf("coin %s: tick_size must be positive", c.Symbol)
+		}
+		if c.Base == "" {
+			c.Base = strings.TrimSuffix(c.Symbol, c.Quote)
+		}
+		if c.Name == "" {
+			c.Name = strings.ToUpper(c.Base + "/" + c.Quote)
+			c.nameDerived = true
+		}
+	}
+
+	coins = list
+	return nil
+}
+
+// addIndexCoins lists each index (INDEXES) as a coin, so it can be selected
+// and displayed like any other. Index values are points, not a currency.
+func addIndexCoins(set indexdef.Set) error {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, ok := findCoin(name); ok {
+			return fmt.Errorf("index %s has the same symbol as a coin", name)
+		}
+		basket := strings.ToUpper(strings.Join(set[name].Constituents, "+"))
+		coins = append(coins, Coin{
+			Symbol:    name,
+			Name:      strings.ToUpper(name) + " Index (" + basket + ")",
+			Base:      name,
+			Quote:     "pts",
+			Precision: 2,
+			index:     true,
+		})
+	}
+	return nil
+}
+
+func findCoin(symbol string) (Coin, bool) {
+	for _, c := range coins {
+		if c.Symbol == symbol {
+			return c, true
+		}
+	}
+	return Coin{}, false
+}
+
+func getCoinName(symbol string) string {
+	if c, ok := findCoin(symbol); ok {
+		return c.Name
+	}
+	return symbol
+}
+
+// symbolInfo is the /api/symbol response body
+func symbolInfo(symbol, name string) map[string]interface{} {
+	c, _ := findCoin(symbol)
+	return map[string]interface{}{
+		"symbol":    symbol,
+		"name":      name,
+		"quote":     c.Quote,
+		"precision": c.Precision,
+	}
+}
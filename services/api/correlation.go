@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Bounds for the correlation lookback window
+const (
+	minCorrelationWindow = time.Minute
+	maxCorrelationWindow = 7 * 24 * time.Hour
+)
+
+// handleCorrelation returns the Pearson correlation between two symbols'
+// prices over a window. Trades are averaged into time buckets first so
+// symbols with different trade rates line up sample for sample.
+func (s *Server) handleCorrelation(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSONErrorCode(w, http.StatusServiceUnavailable, codeDBUnavailable, "Database not available")
+		return
+	}
+
+	q := r.URL.Query()
+	a, b := q.Get("a"), q.Get("b")
+	if a == "" || b == "" || a == b {
+		writeJSONError(w, http.StatusBadRequest, "Two different symbols required (?a=&b=)")
+		return
+	}
+
+	window := time.Hour
+	if v := q.Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < minCorrelationWindow || d > maxCorrelationWindow {
+			writeJSONError(w, http.StatusBadRequest, "Invalid window (1m to 168h)")
+			return
+		}
+		window = d
+	}
+
+	// Aim for ~100 buckets across the window, never finer than a second
+	bucket := (window / 100).Truncate(time.Second)
+	if bucket < time.Second {
+		bucket = time.Second
+	}
+
+	var corr *float64
+	var samples int
+	err := s.db.QueryRow(r.Context(), `
+		WITH a AS (
+			SELECT time_bucket($1::interval, time) AS bucket, avg(price) AS price
+			FROM trades WHERE symbol = $2 AND time > now() - $4::interval
+			GROUP BY bucket
+		), b AS (
+			SELECT time_bucket($1::interval, time) AS bucket, avg(price) AS price
+			FROM trades WHERE symbol = $3 AND time > now() - $4::interval
+			GROUP BY bucket
+		)
+		SELECT corr(a.price, b.price), count(*)
+		FROM a JOIN b USING (bucket)`,
+		bucket, a, b, window).Scan(&corr, &samples)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to compute correlation")
+		return
+	}
+
+	// corr is null with fewer than two aligned buckets or a flat series
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"a":           a,
+		"b":           b,
+		"window":      window.String(),
+		"bucket":      bucket.String(),
+		"samples":     samples,
+		"correlation": corr,
+	})
+}
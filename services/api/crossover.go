@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/nats-io/nats.go"
+)
+
+// handleCrossover forwards processing's MA crossover events
+// (events.crossover) to /ws clients as "crossover" messages. The event is
+// passed through as the message data.
+func (s *Server) handleCrossover(msg *nats.Msg) {
+	var event struct {
+		Symbol    string `json:"symbol"`
+		Direction string `json:"direction"`
+	}
+	if err := json.Unmarshal(msg.Data, &event); err != nil || event.Direction == "" {
+		return
+	}
+	metrics.Add("crossover_events", 1)
+
+	// Events for a symbol that's no longer active are stale
+	s.mu.RLock()
+	active := event.Symbol == s.symbol
+	s.mu.RUnlock()
+	if !active {
+		return
+	}
+
+	out := appendEnvelope(make([]byte, 0, len(msg.Data)+48), wsTypeCrossover)
+	out = append(out, msg.Data...)
+	s.ws.Broadcast(append(out, '}'))
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// tradeRow is one pending insert into the trades table. Indicators is only
+// set with STORE_INDICATORS.
+type tradeRow struct {
+	Time       time.Time         `json:"time"`
+	Symbol     string            `json:"symbol"`
+	Price      float64           `json:"price"`
+	Indicators *StoredIndicators `json:"indicators,omitempty"`
+}
+
+// Worker batching: a batch is copied once it is full or has waited this long
+const (
+	maxWriteBatch      = 500
+	writeFlushInterval = 100 * time.Millisecond
+	workerQueueSize    = 1000
+	syncWriteTimeout   = 5 * time.Second
+)
+
+// dbWriter batches trades into the database through a pool of COPY
+// workers, one per symbol so rows land in order, with an ordered retry
+// buffer (optionally spilled to disk) for failed batches
+type dbWriter struct {
+	db         *pgxpool.Pool
+	spillPath  string
+	max        int
+	sync       bool
+	indicators bool
+	stored     func(symbol string)
+	copy       func(batch []tradeRow) error
+
+	mu      sync.Mutex
+	pending []tradeRow // oldest first
+	dropped int        // rows evicted from the front, total
+	closed  bool
+
+	overflow atomic.Uint64 // rows dropped at a full worker queue
+
+	sendMu  sync.RWMutex // held for reading while sending to a worker, so Close can't close its queue mid-send
+	workers []chan tradeRow
+	wg      sync.WaitGroup
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newDBWriter(db *pgxpool.Pool, max int, spillPath string, workers int, sync, indicators bool, stored func(symbol string)) *dbWriter {
+	if sync {
+		workers = 0
+	}
+	w := &dbWriter{
+		db:         db,
+		spillPath:  spillPath,
+		max:        max,
+		sync:       sync,
+		indicators: indicators,
+		stored:     stored,
+		workers:    make([]chan tradeRow, workers),
+		wake:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	w.copy = w.copyBatch
+
+	if spillPath != "" {
+		if n, err := w.loadSpill(); err != nil {
+			log.Printf("Failed to load write buffer from %s: %v", spillPath, err)
+		} else if n > 0 {
+			log.Printf("Recovered %d buffered trades from %s", n, spillPath)
+			w.signal()
+		}
+	}
+
+	metrics.Gauge("db_buffer_depth", func() float64 {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return float64(len(w.pending))
+	})
+
+	for i := range w.workers {
+		rows := make(chan tradeRow, workerQueueSize)
+		w.workers[i] = rows
+		metrics.Gauge("db_worker_"+strconv.Itoa(i)+"_queue", func() float64 {
+			return float64(len(rows))
+		})
+		w.wg.Add(1)
+		go w.worker(i, rows)
+	}
+
+	go w.retryLoop()
+	return w
+}
+
+// Write queues row for its symbol's worker, or in sync mode inserts it.
+// It never waits on a full queue: the row is dropped and counted.
+func (w *dbWriter) Write(row tradeRow) {
+	if w.sync {
+		w.writeSync(row)
+		return
+	}
+
+	w.sendMu.RLock()
+	defer w.sendMu.RUnlock()
+	if w.closed {
+		return
+	}
+
+	rows := w.workers[w.route(row.Symbol)]
+	select {
+	case rows <- row:
+	default:
+		metrics.Add("db_worker_dropped", 1)
+		if n := w.overflow.Add(1); n == 1 || n%1000 == 0 {
+			log.Printf("DB worker queue full, dropped %s trade (%d so far)", row.Symbol, n)
+		}
+	}
+}
+
+// writeSync inserts row within syncWriteTimeout. If that fails, or the
+// retry buffer already holds rows, row goes to the buffer instead.
+func (w *dbWriter) writeSync(row tradeRow) {
+	w.mu.Lock()
+	closed, buffered := w.closed, len(w.pending) > 0
+	w.mu.Unlock()
+	if closed {
+		return
+	}
+
+	if !buffered {
+		query := `INSERT INTO trades (time, symbol, price) VALUES ($1, $2, $3)`
+		args := []any{row.Time, row.Symbol, row.Price}
+		if w.indicators {
+			query = `INSERT INTO trades (time, symbol, price, ` + strings.Join(indicatorColumns, ", ") +
+				`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+			args = append(args, row.Indicators.values()...)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), syncWriteTimeout)
+		_, err := w.db.Exec(ctx, query, args...)
+		cancel()
+		if err == nil {
+			metrics.Add("db_sync_writes", 1)
+			if w.stored != nil {
+				w.stored(row.Symbol)
+			}
+			return
+		}
+		log.Printf("DB write error, buffering: %v", err)
+		metrics.Add("db_sync_errors", 1)
+	}
+
+	w.mu.Lock()
+	w.enqueueLocked(row)
+	w.mu.Unlock()
+	w.signal()
+}
+
+// route picks the worker that owns symbol
+func (w *dbWriter) route(symbol string) int {
+	h := fnv.New32a()
+	h.Write([]byte(symbol))
+	return int(h.Sum32() % uint32(len(w.workers)))
+}
+
+// worker copies rows in batches until its queue is closed
+func (w *dbWriter) worker(id int, rows <-chan tradeRow) {
+	defer w.wg.Done()
+	prefix := "db_worker_" + strconv.Itoa(id)
+
+	ticker := time.NewTicker(writeFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]tradeRow, 0, maxWriteBatch)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		// Go through the buffer while it has rows, so nothing overtakes them
+		var err error
+		buffered := w.depth() > 0
+		if !buffered {
+			err = w.copy(batch)
+		}
+		if buffered || err != nil {
+			if err != nil {
+				log.Printf("DB write error, buffering %d rows: %v", len(batch), err)
+				metrics.Add(prefix+"_errors", 1)
+			}
+			w.mu.Lock()
+			for _, row := range batch {
+				w.enqueueLocked(row)
+			}
+			w.mu.Unlock()
+			w.signal()
+		} else {
+			metrics.Add(prefix+"_batches", 1)
+			metrics.Add(prefix+"_rows", int64(len(batch)))
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case row, ok := <-rows:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, row)
+			if len(batch) >= maxWriteBatch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// enqueueLocked appends row, dropping the oldest when the buffer is full.
+// Caller must hold w.mu.
+func (w *dbWriter) enqueueLocked(row tradeRow) {
+	if len(w.pending) >= w.max {
+		w.pending = w.pending[1:]
+		w.dropped++
+		metrics.Add("db_buffer_dropped", 1)
+	}
+	w.pending = append(w.pending, row)
+}
+
+func (w *dbWriter) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// retryLoop drains the buffer in order, backing off while the DB is down
+func (w *dbWriter) retryLoop() {
+	defer close(w.done)
+
+	const maxBackoff = 30 * time.Second
+	backoff := time.Second
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-w.wake:
+		}
+
+		for {
+			w.mu.Lock()
+			n := min(len(w.pending), maxWriteBatch)
+			batch := append([]tradeRow(nil), w.pending[:n]...)
+			droppedBefore := w.dropped
+			w.mu.Unlock()
+
+			if len(batch) == 0 {
+				backoff = time.Second
+				break
+			}
+
+			if err := w.copy(batch); err != nil {
+				log.Printf("DB still unavailable (%d buffered), retrying in %s: %v", w.depth(), backoff, err)
+				select {
+				case <-w.stop:
+					return
+				case <-time.After(backoff):
+				}
+				backoff *= 2
+				if backoff > maxBackoff {
+					backoff = maxBackoff
+				}
+				continue
+			}
+
+			// Rows may have been evicted from the front while we were
+			// writing; only remove what's still there
+			w.mu.Lock()
+			if remove := len(batch) - (w.dropped - droppedBefore); remove > 0 {
+				w.pending = w.pending[remove:]
+			}
+			w.mu.Unlock()
+			metrics.Add("db_buffer_flushed", int64(len(batch)))
+			backoff = time.Second
+		}
+	}
+}
+
+func (w *dbWriter) copyBatch(batch []tradeRow) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	columns := []string{"time", "symbol", "price"}
+	if w.indicators {
+		columns = append(columns, indicatorColumns...)
+	}
+	_, err := w.db.CopyFrom(ctx,
+		pgx.Identifier{"trades"},
+		columns,
+		pgx.CopyFromSlice(len(batch), func(i int) ([]any, error) {
+			row := []any{batch[i].Time, batch[i].Symbol, batch[i].Price}
+			if w.indicators {
+				row = append(row, batch[i].Indicators.values()...)
+			}
+			return row, nil
+		}))
+	if err == nil && w.stored != nil {
+		seen := make(map[string]bool)
+		for _, row := range batch {
+			if !seen[row.Symbol] {
+				seen[row.Symbol] = true
+				w.stored(row.Symbol)
+			}
+		}
+	}
+	return err
+}
+
+func (w *dbWriter) depth() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.pending)
+}
+
+// Close flushes the workers, stops retrying and, if configured, saves the
+// buffer to disk
+func (w *dbWriter) Close() {
+	w.sendMu.Lock()
+	w.mu.Lock()
+	w.closed = true
+	for _, rows := range w.workers {
+		close(rows)
+	}
+	w.mu.Unlock()
+	w.sendMu.Unlock()
+	w.wg.Wait()
+
+	close(w.stop)
+	<-w.done
+
+	if w.spillPath == "" {
+		return
+	}
+	if err := w.saveSpill(); err != nil {
+		log.Printf("Failed to save write buffer to %s: %v", w.spillPath, err)
+	}
+}
+
+func (w *dbWriter) loadSpill() (int, error) {
+	f, err := os.Open(w.spillPath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var row tradeRow
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			continue
+		}
+		w.enqueueLocked(row)
+		n++
+	}
+	return n, scanner.Err()
+}
+
+func (w *dbWriter) saveSpill() error {
+	w.mu.Lock()
+	pending := w.pending
+	w.mu.Unlock()
+
+	if len(pending) == 0 {
+		err := os.Remove(w.spillPath)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	tmp := w.spillPath + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	enc := json.NewEncoder(bw)
+	for _, row := range pending {
+		enc.Encode(row)
+	}
+	if err := bw.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	log.Printf("Saved %d buffered trades to %s", len(pending), w.spillPath)
+	return os.Rename(tmp, w.spillPath)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// The trades.processed handler writes before it broadcasts. With the
+// database stalled, Write must drop once the worker's queue is full rather
+// than wait, so the broadcast still goes out.
+func TestDBWriterStalledCopy(t *testing.T) {
+	w := newDBWriter(nil, 10, "", 1, false, false, nil)
+	release := make(chan struct{})
+	w.copy = func([]tradeRow) error {
+		<-release
+		return nil
+	}
+	defer func() {
+		close(release)
+		w.Close()
+	}()
+
+	h := newHub(0, realClock{})
+	conns := dialHub(t, h, 1)
+
+	// More rows than the worker can hold: one stalled batch plus a full queue
+	n := maxWriteBatch + workerQueueSize + 100
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < n; i++ {
+			w.Write(tradeRow{Time: time.Now(), Symbol: "btcusdt", Price: 1})
+		}
+		h.Broadcast([]byte("tick"))
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Write blocked behind the stalled copy")
+	}
+	if got := read(t, conns[0].client); got != "tick" {
+		t.Errorf("client got %q, want the broadcast", got)
+	}
+	if dropped := w.overflow.Load(); dropped == 0 {
+		t.Error("no rows counted as dropped")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// deltaFilter skips DB writes for prices that barely moved since the last
+// stored price for the same symbol
+type deltaFilter struct {
+	mu      sync.Mutex
+	abs     float64 // minimum absolute move, or
+	percent float64 // minimum move in percent of the last stored price
+	last    map[string]float64
+}
+
+// parseDeltaFilter reads MIN_PRICE_DELTA: "0.5" is an absolute move,
+// "0.01%" a relative one
+func parseDeltaFilter(v string) (*deltaFilter, error) {
+	f := &deltaFilter{last: make(map[string]float64)}
+	num, isPercent := strings.CutSuffix(strings.TrimSpace(v), "%")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n < 0 || math.IsInf(n, 0) {
+		return nil, fmt.Errorf("invalid MIN_PRICE_DELTA %q (e.g. 0.5 or 0.01%%)", v)
+	}
+	if isPercent {
+		f.percent = n
+	} else {
+		f.abs = n
+	}
+	return f, nil
+}
+
+// moved reports whether price is far enough from the last stored price
+func (f *deltaFilter) moved(symbol string, price float64) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	last, ok := f.last[symbol]
+	if !ok {
+		return true
+	}
+	move := math.Abs(price - last)
+	if f.percent > 0 && last != 0 {
+		return move/math.Abs(last)*100 > f.percent
+	}
+	return move > f.abs
+}
+
+// stored records price as the last one persisted for symbol
+func (f *deltaFilter) stored(symbol string, price float64) {
+	f.mu.Lock()
+	f.last[symbol] = price
+	f.mu.Unlock()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// DepthLevel is one price level of the order book
+type DepthLevel struct {
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity"`
+}
+
+// DepthMessage from ingestion service (order book snapshot, best first)
+type DepthMessage struct {
+	Symbol       string       `json:"symbol"`
+	Bids         []DepthLevel `json:"bids"`
+	Asks         []DepthLevel `json:"asks"`
+	LastUpdateID int64        `json:"last_update_id"`
+	Time         int64        `json:"time"`
+}
+
+// handleDepth returns the latest depth snapshot for a symbol, cut to
+// ?levels= per side when given
+func (s *Server) handleDepth(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	symbol := q.Get("symbol")
+	if symbol == "" {
+		s.mu.RLock()
+		symbol = s.symbol
+		s.mu.RUnlock()
+	}
+
+	levels := 0
+	if v := q.Get("levels"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid levels")
+			return
+		}
+		levels = n
+	}
+
+	s.booksMu.RLock()
+	depth, ok := s.depths[symbol]
+	s.booksMu.RUnlock()
+	if !ok {
+		writeJSONErrorCode(w, http.StatusNotFound, codeNoData, "No depth data for symbol")
+		return
+	}
+
+	bids, asks := depth.Bids, depth.Asks
+	if levels > 0 {
+		bids = bids[:min(levels, len(bids))]
+		asks = asks[:min(levels, len(asks))]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"symbol":         depth.Symbol,
+		"bids":           bids,
+		"asks":           asks,
+		"last_update_id": depth.LastUpdateID,
+		"time":           depth.Time,
+	})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Error codes sent as "code" in JSON error bodies, so clients can branch
+// on the cause without matching the message. Errors without a specific
+// code get one for their status from statusErrorCode.
+const (
+	codeUnknownSymbol = "unknown_symbol"
+	codeDBUnavailable = "db_unavailable"
+	codeNoData        = "no_data" // nothing received for the symbol yet
+)
+
+// statusErrorCode is the code for an error with no more specific one
+func statusErrorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusMethodNotAllowed:
+		return "method_not_allowed"
+	case http.StatusBadGateway:
+		return "upstream_error"
+	case http.StatusServiceUnavailable:
+		return "unavailable"
+	}
+	return "internal"
+}
+
+// writeJSONErrorCode is writeJSONError with a specific code
+func writeJSONErrorCode(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  msg,
+		"code":   code,
+		"status": status,
+	})
+}
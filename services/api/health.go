@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// handleHealthz answers as long as the process is serving HTTP, for
+// liveness probes
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// handleReadyz reports whether the API can serve live data, for readiness
+// probes: 503 while NATS is down (at startup, the API serves HTTP without
+// it and keeps retrying). The database isn't needed for live data, so it's
+// only reported.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	status, code := "ok", http.StatusOK
+	natsState := "connected"
+	if s.nc == nil || !s.nc.IsConnected() {
+		status, code, natsState = "nats disconnected", http.StatusServiceUnavailable, "disconnected"
+	}
+	db := "available"
+	if s.db == nil {
+		db = "unavailable"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": status,
+		"nats":   natsState,
+		"db":     db,
+	})
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"strconv"
+	"time"
+)
+
+// sendHeartbeats queues a heartbeat message ({"time":<ms>}) for every
+// WebSocket client that hasn't been sent anything for interval, so clients
+// on quiet symbols can tell an idle connection from a dead one. Checking
+// twice per interval keeps the longest silence under 1.5x interval.
+func (s *Server) sendHeartbeats(interval time.Duration) {
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := s.clock.Now()
+		msg := appendEnvelope(nil, wsTypeHeartbeat)
+		msg = append(msg, `{"time":`...)
+		msg = strconv.AppendInt(msg, now.UnixMilli(), 10)
+		msg = append(msg, "}}"...)
+		s.ws.broadcastIdle(msg, now.Add(-interval))
+	}
+}
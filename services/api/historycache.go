@@ -0,0 +1,106 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// maxHistoryCacheEntries bounds the cache; pollers passing a new ?since=
+// every time would otherwise grow it without limit
+const maxHistoryCacheEntries = 1000
+
+// historyKey identifies one /api/history query
+type historyKey struct {
+	symbol string
+	limit  int
+	since  string
+	maxAge time.Duration
+}
+
+type historyEntry struct {
+	body    []byte
+	expires time.Time
+}
+
+// historyCache keeps encoded /api/history responses for a short TTL
+// (HISTORY_CACHE_TTL), so dashboards polling the same query share one
+// database read. The DB writer drops a symbol's entries as soon as it
+// stores new trades for it, so a hit is never behind the database. A nil
+// cache (TTL 0) caches nothing.
+type historyCache struct {
+	ttl   time.Duration
+	clock Clock
+
+	mu      sync.Mutex
+	entries map[historyKey]historyEntry
+	gen     map[string]uint64 // bumped on every store for the symbol
+}
+
+func newHistoryCache(ttl time.Duration, clock Clock) *historyCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &historyCache{
+		ttl:     ttl,
+		clock:   clock,
+		entries: make(map[historyKey]historyEntry),
+		gen:     make(map[string]uint64),
+	}
+}
+
+// get returns the cached body for key. On a miss it also returns the
+// symbol's generation, to hand back to put once the query is done.
+func (c *historyCache) get(key historyKey) (body []byte, gen uint64, ok bool) {
+	if c == nil {
+		return nil, 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, found := c.entries[key]; found && c.clock.Now().Before(e.expires) {
+		metrics.Add("history_cache_hits", 1)
+		return e.body, 0, true
+	}
+	metrics.Add("history_cache_misses", 1)
+	return nil, c.gen[key.symbol], false
+}
+
+// put caches body for key, unless trades were stored for the symbol since
+// get: the query may not have seen them
+func (c *historyCache) put(key historyKey, gen uint64, body []byte) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen[key.symbol] != gen {
+		return
+	}
+	now := c.clock.Now()
+	if len(c.entries) >= maxHistoryCacheEntries {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxHistoryCacheEntries {
+			clear(c.entries)
+		}
+	}
+	c.entries[key] = historyEntry{body: body, expires: now.Add(c.ttl)}
+}
+
+// invalidate drops symbol's entries; the DB writer calls it after storing
+// trades for symbol
+func (c *historyCache) invalidate(symbol string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen[symbol]++
+	for k := range c.entries {
+		if k.symbol == symbol {
+			delete(c.entries, k)
+		}
+	}
+}
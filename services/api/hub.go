@@ -0,0 +1,218 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsSendBuffer is how many messages a WebSocket client can have queued
+// before further messages to it are dropped
+const wsSendBuffer = 64
+
+// wsWriteTimeout is how long a write to a client may block before the
+// client is dropped: one that stops reading would otherwise hold its writer
+// forever once the socket buffers fill
+const wsWriteTimeout = 10 * time.Second
+
+// Hub owns a set of WebSocket connections and fans messages out to them.
+// Each client has a buffered queue drained by its own writer goroutine, so
+// a slow client only falls behind (and loses messages once its queue is
+// full) instead of holding up the broadcast, and one that stalls for
+// writeTimeout is disconnected. The writer is the only
+// goroutine that writes data frames, and Unregister is the only place a
+// client is removed, so a connection is closed and its queue released
+// exactly once however it goes away.
+type Hub struct {
+	mu        sync.RWMutex
+	clients   map[*websocket.Conn]*wsClient
+	max       int // 0 means unlimited
+	upgrading int // slots reserved by in-flight upgrades
+	clock     Clock
+
+	writeTimeout time.Duration
+}
+
+func newHub(max int, clock Clock) *Hub {
+	return &Hub{
+		clients: make(map[*websocket.Conn]*wsClient),
+		max:     max,
+		clock:   clock,
+
+		writeTimeout: wsWriteTimeout,
+	}
+}
+
+// reserve claims a slot for an upgrade about to start, so concurrent
+// handshakes can't overshoot max. Every successful reserve must be
+// followed by Register or release.
+func (h *Hub) reserve() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.max > 0 && len(h.clients)+h.upgrading >= h.max {
+		return false
+	}
+	h.upgrading++
+	return true
+}
+
+func (h *Hub) release() {
+	h.mu.Lock()
+	h.upgrading--
+	h.mu.Unlock()
+}
+
+// Register adds an upgraded connection, taking over the slot claimed by
+// reserve, and starts its writer. It returns the new client count.
+func (h *Hub) Register(conn *websocket.Conn, r *http.Request) int {
+	client := &wsClient{
+		remoteAddr:  r.RemoteAddr,
+		connectedAt: h.clock.Now(),
+		send:        make(chan []byte, wsSendBuffer),
+	}
+	client.lastSent.Store(client.connectedAt.UnixNano())
+
+	h.mu.Lock()
+	h.upgrading--
+	h.clients[conn] = client
+	total := len(h.clients)
+	h.mu.Unlock()
+
+	go h.write(conn, client)
+	return total
+}
+
+// Unregister closes conn and removes it. It's safe to call more than once
+// and from any goroutine; only the first call has any effect.
+func (h *Hub) Unregister(conn *websocket.Conn) (total int, removed bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	client, ok := h.clients[conn]
+	if ok {
+		delete(h.clients, conn)
+		// Broadcast sends under the read lock, so nothing can be sending
+		// on the queue while it's closed here
+		close(client.send)
+		conn.Close()
+	}
+	return len(h.clients), ok
+}
+
+// write drains client's queue onto conn until Unregister closes it
+func (h *Hub) write(conn *websocket.Conn, client *wsClient) {
+	for msg := range client.send {
+		start := time.Now()
+		conn.SetWriteDeadline(start.Add(h.writeTimeout))
+		err := conn.WriteMessage(websocket.TextMessage, msg)
+		client.lastWrite.Store(int64(time.Since(start)))
+		if err != nil {
+			h.Unregister(conn)
+			return
+		}
+		client.sent.Add(1)
+		client.lastSent.Store(h.clock.Now().UnixNano())
+	}
+}
+
+// Broadcast queues msg for every client. msg is shared by all the queues,
+// so the caller must not modify it afterwards.
+func (h *Hub) Broadcast(msg []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, client := range h.clients {
+		client.enqueue(msg)
+	}
+}
+
+// broadcastPrice queues a price message for symbol to the clients that want
+// it: those subscribed to symbol, unless the price (numbered seq by
+// recentPrices) was already in their history batch, and, when active, those
+// following the active symbol
+func (h *Hub) broadcastPrice(symbol string, seq uint64, active bool, msg []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, client := range h.clients {
+		if (client.symbol == "" && active) || (client.symbol == symbol && seq > client.after) {
+			client.enqueue(msg)
+		}
+	}
+}
+
+// Subscribe switches conn to symbol's prices. history returns the batch to
+// send first and the sequence number of the newest price in it. It runs
+// under the write lock, so no price is broadcast between it and the
+// switch: every later price is sent live, and none in the batch is sent
+// again. Reports false if conn is gone.
+func (h *Hub) Subscribe(conn *websocket.Conn, symbol string, history func() ([]byte, uint64)) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	client, ok := h.clients[conn]
+	if !ok {
+		return false
+	}
+	msg, seq := history()
+	client.symbol, client.after = symbol, seq
+	client.enqueue(msg)
+	return true
+}
+
+// Send queues msg for conn alone
+func (h *Hub) Send(conn *websocket.Conn, msg []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if client, ok := h.clients[conn]; ok {
+		client.enqueue(msg)
+	}
+}
+
+// broadcastIdle queues msg for clients that haven't been sent anything
+// since before cutoff
+func (h *Hub) broadcastIdle(msg []byte, cutoff time.Time) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, client := range h.clients {
+		if time.Unix(0, client.lastSent.Load()).Before(cutoff) {
+			client.enqueue(msg)
+		}
+	}
+}
+
+// Len is the number of connected clients
+func (h *Hub) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// snapshot describes every client, for /api/admin/clients
+func (h *Hub) snapshot(symbols []string) []wsClientInfo {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	clients := make([]wsClientInfo, 0, len(h.clients))
+	for _, c := range h.clients {
+		clients = append(clients, c.info(symbols))
+	}
+	return clients
+}
+
+// CloseAll sends every client a going-away close frame with reason, then
+// unregisters it. Close frames can be written alongside the writer
+// goroutine's data frames.
+func (h *Hub) CloseAll(reason string) {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, reason)
+	deadline := time.Now().Add(time.Second)
+
+	h.mu.RLock()
+	conns := make([]*websocket.Conn, 0, len(h.clients))
+	for conn := range h.clients {
+		conns = append(conns, conn)
+	}
+	h.mu.RUnlock()
+
+	for _, conn := range conns {
+		conn.WriteControl(websocket.CloseMessage, msg, deadline)
+		h.Unregister(conn)
+	}
+}
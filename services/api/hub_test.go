@@ -0,0 +1,244 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// hubConn is one connection to a test hub: the server side, which is the
+// Hub's key, and the client that dialed it
+type hubConn struct {
+	server *websocket.Conn
+	client *websocket.Conn
+}
+
+// dialHub starts a server that registers every WebSocket connection with h
+// the way handleWebSocket does, and dials n clients to it
+func dialHub(tb testing.TB, h *Hub, n int) []hubConn {
+	tb.Helper()
+	accepted := make(chan *websocket.Conn, n)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.reserve() {
+			http.Error(w, "full", http.StatusServiceUnavailable)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			h.release()
+			return
+		}
+		h.Register(conn, r)
+		accepted <- conn
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				h.Unregister(conn)
+				return
+			}
+		}
+	}))
+	tb.Cleanup(srv.Close)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	conns := make([]hubConn, n)
+	for i := range conns {
+		client, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			tb.Fatalf("dial: %v", err)
+		}
+		tb.Cleanup(func() { client.Close() })
+		conns[i] = hubConn{server: <-accepted, client: client}
+	}
+	return conns
+}
+
+// read returns the next message on client, failing the test if none
+// arrives in time
+func read(t *testing.T, client *websocket.Conn) string {
+	t.Helper()
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, data, err := client.ReadMessage()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return string(data)
+}
+
+// waitFor polls cond until it holds or a couple of seconds pass
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHubBroadcast(t *testing.T) {
+	h := newHub(0, realClock{})
+	conns := dialHub(t, h, 3)
+	if n := h.Len(); n != 3 {
+		t.Fatalf("Len = %d, want 3", n)
+	}
+
+	h.Broadcast([]byte("one"))
+	h.Broadcast([]byte("two"))
+	for i, c := range conns {
+		if got := read(t, c.client) + " " + read(t, c.client); got != "one two" {
+			t.Errorf("client %d got %q, want in order", i, got)
+		}
+	}
+
+	h.Send(conns[1].server, []byte("just you"))
+	h.Broadcast([]byte("all"))
+	if got := read(t, conns[1].client); got != "just you" {
+		t.Errorf("Send: client got %q", got)
+	}
+	for i, c := range conns {
+		if got := read(t, c.client); got != "all" {
+			t.Errorf("client %d got %q, want all", i, got)
+		}
+	}
+}
+
+func TestHubUnregisterCloses(t *testing.T) {
+	h := newHub(0, realClock{})
+	conns := dialHub(t, h, 2)
+
+	total, removed := h.Unregister(conns[0].server)
+	if !removed || total != 1 {
+		t.Fatalf("Unregister = %d, %v, want 1, true", total, removed)
+	}
+	if _, removed := h.Unregister(conns[0].server); removed {
+		t.Error("second Unregister removed the client again")
+	}
+
+	conns[0].client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := conns[0].client.ReadMessage()
+	if ne, ok := err.(net.Error); err == nil || ok && ne.Timeout() {
+		t.Errorf("read after Unregister: %v, want the connection closed", err)
+	}
+
+	// Sending to a client that's gone is a no-op, and the rest still hear
+	h.Send(conns[0].server, []byte("gone"))
+	h.Broadcast([]byte("still here"))
+	if got := read(t, conns[1].client); got != "still here" {
+		t.Errorf("remaining client got %q", got)
+	}
+}
+
+// A client that disconnects is removed by its reader
+func TestHubClientDisconnect(t *testing.T) {
+	h := newHub(0, realClock{})
+	conns := dialHub(t, h, 2)
+
+	conns[0].client.Close()
+	waitFor(t, "the client to be removed", func() bool { return h.Len() == 1 })
+}
+
+// A client that stops reading loses messages once its queue is full, and
+// is disconnected when a write stalls, without holding up anyone else
+func TestHubSlowClient(t *testing.T) {
+	h := newHub(0, realClock{})
+	h.writeTimeout = 100 * time.Millisecond
+	conns := dialHub(t, h, 2)
+	slow, fast := conns[0], conns[1]
+
+	h.mu.RLock()
+	slowClient := h.clients[slow.server]
+	h.mu.RUnlock()
+
+	var received atomic.Int64
+	go func() {
+		for {
+			if _, _, err := fast.client.ReadMessage(); err != nil {
+				return
+			}
+			received.Add(1)
+		}
+	}()
+
+	// Enough to fill the socket buffers and the queue behind them
+	msg := make([]byte, 1<<20)
+	start := time.Now()
+	for i := 0; i < 4*wsSendBuffer; i++ {
+		h.Broadcast(msg)
+	}
+	if elapsed := time.Since(start); elapsed > h.writeTimeout {
+		t.Errorf("Broadcast took %s with a stalled client", elapsed)
+	}
+
+	waitFor(t, "the slow client to be dropped", func() bool { return h.Len() == 1 })
+	if slowClient.dropped.Load() == 0 {
+		t.Error("no messages dropped for the slow client")
+	}
+
+	h.mu.RLock()
+	_, fastRegistered := h.clients[fast.server]
+	h.mu.RUnlock()
+	if !fastRegistered {
+		t.Fatal("fast client was dropped")
+	}
+	waitFor(t, "the fast client to read", func() bool { return received.Load() > 0 })
+}
+
+func TestHubReserve(t *testing.T) {
+	h := newHub(2, realClock{})
+	dialHub(t, h, 1)
+
+	if !h.reserve() {
+		t.Fatal("reserve refused the last slot")
+	}
+	if h.reserve() {
+		t.Fatal("reserve went over max")
+	}
+	h.release()
+	if !h.reserve() {
+		t.Fatal("released slot not reusable")
+	}
+}
+
+// discard reads from client until it's closed
+func discard(client *websocket.Conn) {
+	for {
+		if _, _, err := client.NextReader(); err != nil {
+			return
+		}
+	}
+}
+
+func BenchmarkBroadcast(b *testing.B) {
+	for _, clients := range []int{10, 100, 1000} {
+		b.Run(strconv.Itoa(clients)+"_clients", func(b *testing.B) {
+			s := &Server{
+				ws:      newHub(0, realClock{}),
+				sse:     newSSEBroker(),
+				quality: newQualityTracker(time.Second, time.Minute, time.Minute, realClock{}),
+				clock:   realClock{},
+			}
+			for _, c := range dialHub(b, s.ws, clients) {
+				go discard(c.client)
+			}
+			msg := ProcessedMessage{Symbol: "btcusdt", Price: 42000.12, Time: time.Now().UnixMilli()}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				msg.Price += 0.01
+				s.broadcast(msg, uint64(i+1), true)
+			}
+			b.StopTimer()
+			s.ws.CloseAll("done")
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Services repeat their status.* announcements every 30s; after missing a
+// few the cached copy is reported as stale
+const statusStaleAfter = 90 * time.Second
+
+// statusCache holds the latest message on a status subject (for example
+// status.indicators from processing)
+type statusCache struct {
+	mu       sync.RWMutex
+	raw      json.RawMessage
+	received time.Time
+	clock    Clock
+}
+
+func (st *statusCache) update(msg *nats.Msg) {
+	st.mu.Lock()
+	st.raw = append(json.RawMessage(nil), msg.Data...)
+	st.received = st.clock.Now()
+	st.mu.Unlock()
+}
+
+// get returns the cached message and when it arrived; raw is nil if none has
+func (st *statusCache) get() (json.RawMessage, time.Time) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return st.raw, st.received
+}
+
+// handleIndicators returns the indicators processing computes and their
+// parameters, as last announced
+func (s *Server) handleIndicators(w http.ResponseWriter, r *http.Request) {
+	raw, received := s.indicators.get()
+
+	if raw == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "No indicator status from processing yet")
+		return
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		writeJSONError(w, http.StatusBadGateway, "Malformed indicator status from processing")
+		return
+	}
+	body["updated"] = received.UTC().Format(time.RFC3339)
+	body["stale"] = s.clock.Now().Sub(received) > statusStaleAfter
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// indicatorColumns are the trades columns STORE_INDICATORS fills, in the
+// order StoredIndicators.values returns them
+var indicatorColumns = []string{
+	"moving_average", "high", "low", "rolling_high", "rolling_low", "atr", "vol_weighted_change",
+}
+
+// StoredIndicators are the indicator values stored with a trade when
+// STORE_INDICATORS is set. Indicators processing doesn't compute
+// (INDICATORS), or hasn't got enough data for yet, are null.
+type StoredIndicators struct {
+	MovingAverage     *float64 `json:"moving_average,omitempty"`
+	High              *float64 `json:"high,omitempty"`
+	Low               *float64 `json:"low,omitempty"`
+	RollingHigh       *float64 `json:"rolling_high,omitempty"`
+	RollingLow        *float64 `json:"rolling_low,omitempty"`
+	ATR               *float64 `json:"atr,omitempty"`
+	VolWeightedChange *float64 `json:"vol_weighted_change,omitempty"`
+}
+
+// indicatorsOf takes the indicators from a processed trade. Processing
+// leaves out indicators it doesn't compute, which decode as 0 here; no
+// price-based indicator is really 0, so those are stored as null.
+func indicatorsOf(p ProcessedMessage) *StoredIndicators {
+	return &StoredIndicators{
+		MovingAverage:     nonZero(p.MovingAverage),
+		High:              nonZero(p.High),
+		Low:               nonZero(p.Low),
+		RollingHigh:       nonZero(p.RollingHigh),
+		RollingLow:        nonZero(p.RollingLow),
+		ATR:               p.ATR,
+		VolWeightedChange: p.VolWeightedChange,
+	}
+}
+
+func nonZero(v float64) *float64 {
+	if v == 0 {
+		return nil
+	}
+	return &v
+}
+
+// values are the indicatorColumns values to insert; a nil receiver (a row
+// buffered before STORE_INDICATORS was set) stores all nulls
+func (s *StoredIndicators) values() []any {
+	if s == nil {
+		return make([]any, len(indicatorColumns))
+	}
+	return []any{s.MovingAverage, s.High, s.Low, s.RollingHigh, s.RollingLow, s.ATR, s.VolWeightedChange}
+}
+
+// scanTargets are where to scan indicatorColumns into
+func (s *StoredIndicators) scanTargets() []any {
+	return []any{&s.MovingAverage, &s.High, &s.Low, &s.RollingHigh, &s.RollingLow, &s.ATR, &s.VolWeightedChange}
+}
+
+// addIndicatorColumns adds the nullable indicator columns to trades. Rows
+// stored before, or while STORE_INDICATORS is off, have nulls there.
+func addIndicatorColumns(db *pgxpool.Pool) error {
+	ctx := context.Background()
+	for _, col := range indicatorColumns {
+		if _, err := db.Exec(ctx, `ALTER TABLE trades ADD COLUMN IF NOT EXISTS `+col+` DOUBLE PRECISION`); err != nil {
+			return err
+		}
+	}
+	return nil
+}
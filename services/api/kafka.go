@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaSink mirrors processed trades to a Kafka topic (KAFKA_BROKERS,
+// KAFKA_TOPIC), keyed by symbol so each symbol stays on one partition and
+// in order. Writes are asynchronous: the NATS subscriber never waits on
+// Kafka, and failed deliveries are only counted and logged.
+type kafkaSink struct {
+	w        *kafka.Writer
+	failures atomic.Uint64
+}
+
+func newKafkaSink(brokers []string, topic string) *kafkaSink {
+	k := &kafkaSink{}
+	k.w = &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireOne,
+		BatchTimeout: 50 * time.Millisecond,
+		Async:        true,
+		Completion:   k.completed,
+	}
+	return k
+}
+
+// publish queues p for delivery without blocking
+func (k *kafkaSink) publish(p ProcessedMessage) {
+	value, err := json.Marshal(p)
+	if err != nil {
+		return
+	}
+	err = k.w.WriteMessages(context.Background(), kafka.Message{Key: []byte(p.Symbol), Value: value})
+	if err != nil {
+		k.failed(1, err)
+	}
+}
+
+// completed is called with each batch once Kafka has accepted or rejected it
+func (k *kafkaSink) completed(messages []kafka.Message, err error) {
+	if err != nil {
+		k.failed(len(messages), err)
+		return
+	}
+	metrics.Add("kafka_delivered", int64(len(messages)))
+}
+
+func (k *kafkaSink) failed(n int, err error) {
+	metrics.Add("kafka_delivery_failures", int64(n))
+	// Log a sample rather than every batch while Kafka is down
+	total := k.failures.Add(uint64(n))
+	if total == uint64(n) || total/1000 != (total-uint64(n))/1000 {
+		log.Printf("Kafka delivery failed for %d trades (%d so far): %v", n, total, err)
+	}
+}
+
+// Close flushes queued messages
+func (k *kafkaSink) Close() error {
+	return k.w.Close()
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+// runNATS starts an in-process NATS server for the test and connects to it
+func runNATS(tb testing.TB) *nats.Conn {
+	tb.Helper()
+	ns, err := server.NewServer(&server.Options{
+		Host:   "127.0.0.1",
+		Port:   server.RANDOM_PORT,
+		NoLog:  true,
+		NoSigs: true,
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	go ns.Start()
+	tb.Cleanup(ns.Shutdown)
+	if !ns.ReadyForConnections(5 * time.Second) {
+		tb.Fatal("NATS server not ready")
+	}
+
+	nc, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(nc.Close)
+	return nc
+}
+
+// Trades for the old symbol keep arriving during a switch while clients
+// poll /api/stats and an admin resets the processor. Once the switch has
+// returned, /api/stats must never show the old symbol's values. Run with
+// -race.
+func TestSymbolSwitchDuringStatsReads(t *testing.T) {
+	nc := runNATS(t)
+	controls, err := nc.SubscribeSync("control.>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{
+		symbol:   "btcusdt",
+		coinName: getCoinName("btcusdt"),
+		latest:   make(map[string]ProcessedMessage),
+		nc:       nc,
+	}
+	const oldAverage = 42000.0
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	run := func(f func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					f()
+				}
+			}
+		}()
+	}
+
+	// In-flight trades for the previous symbol
+	run(func() {
+		s.applyProcessed(ProcessedMessage{Symbol: "btcusdt", Price: 42001, MovingAverage: oldAverage})
+	})
+
+	var switched atomic.Bool
+	var stale atomic.Int64
+	for i := 0; i < 4; i++ {
+		run(func() {
+			after := switched.Load()
+			rec := httptest.NewRecorder()
+			s.handleStats(rec, httptest.NewRequest(http.MethodGet, "/api/stats", nil))
+			var stats struct {
+				MovingAverage float64 `json:"moving_average"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+				t.Errorf("stats: %v", err)
+				return
+			}
+			if after && stats.MovingAverage == oldAverage {
+				stale.Add(1)
+			}
+		})
+	}
+
+	run(func() {
+		rec := httptest.NewRecorder()
+		s.handleAdminReset(rec, httptest.NewRequest(http.MethodPost, "/api/admin/reset", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("reset: %d %s", rec.Code, rec.Body)
+		}
+	})
+
+	time.Sleep(50 * time.Millisecond)
+	rec := httptest.NewRecorder()
+	s.handleSymbol(rec, httptest.NewRequest(http.MethodPost, "/api/symbol", strings.NewReader(`{"symbol":"ethusdt"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("switch: %d %s", rec.Code, rec.Body)
+	}
+	switched.Store(true)
+
+	// New-symbol trades take over while the old ones are still arriving
+	time.Sleep(20 * time.Millisecond)
+	s.applyProcessed(ProcessedMessage{Symbol: "ethusdt", Price: 2001, MovingAverage: 2000})
+	time.Sleep(50 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	if n := stale.Load(); n > 0 {
+		t.Errorf("%d /api/stats reads showed the old symbol after the switch", n)
+	}
+	if avg := s.current.MovingAverage; avg != 2000 {
+		t.Errorf("current moving average = %v, want the new symbol's", avg)
+	}
+	if p := s.latest["btcusdt"]; p.Price != 42001 {
+		t.Errorf("old symbol's latest = %+v, want it still recorded", p)
+	}
+
+	// The switch went out on control.symbol
+	if err := nc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		msg, err := controls.NextMsg(time.Second)
+		if err != nil {
+			t.Fatal("no control.symbol message")
+		}
+		if msg.Subject == "control.symbol" {
+			if string(msg.Data) != `{"symbol":"ethusdt"}` {
+				t.Errorf("control.symbol = %s", msg.Data)
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// Metrics is a minimal registry of counters and gauges served as JSON
+type Metrics struct {
+	mu       sync.Mutex
+	counters map[string]int64
+	gauges   map[string]func() float64
+}
+
+var metrics = &Metrics{
+	counters: make(map[string]int64),
+	gauges:   make(map[string]func() float64),
+}
+
+// Add increments the named counter by n
+func (m *Metrics) Add(name string, n int64) {
+	m.mu.Lock()
+	m.counters[name] += n
+	m.mu.Unlock()
+}
+
+// Gauge registers fn to be sampled whenever metrics are read
+func (m *Metrics) Gauge(name string, fn func() float64) {
+	m.mu.Lock()
+	m.gauges[name] = fn
+	m.mu.Unlock()
+}
+
+// Snapshot returns the current value of every counter and gauge
+func (m *Metrics) Snapshot() map[string]float64 {
+	m.mu.Lock()
+	out := make(map[string]float64, len(m.counters)+len(m.gauges))
+	for name, v := range m.counters {
+		out[name] = float64(v)
+	}
+	gauges := make(map[string]func() float64, len(m.gauges))
+	for name, fn := range m.gauges {
+		gauges[name] = fn
+	}
+	m.mu.Unlock()
+
+	// Sample gauges outside the lock; they may take their own locks
+	for name, fn := range gauges {
+		out[name] = fn()
+	}
+	return out
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metrics.Snapshot())
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/nats-io/nats.go"
+)
+
+// handleStatsQuery answers query.stats requests with the latest
+// ProcessedMessage for {"symbol": "..."} (empty means the active symbol),
+// or {"error": "..."}, so in-cluster services can skip the HTTP API
+func (s *Server) handleStatsQuery(msg *nats.Msg) {
+	var req struct {
+		Symbol string `json:"symbol"`
+	}
+	if len(msg.Data) > 0 {
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			s.replyJSON(msg, map[string]string{"error": "invalid request"})
+			return
+		}
+	}
+
+	s.mu.RLock()
+	symbol := req.Symbol
+	if symbol == "" {
+		symbol = s.symbol
+	}
+	latest, ok := s.latest[symbol]
+	s.mu.RUnlock()
+
+	if !ok {
+		s.replyJSON(msg, map[string]string{"error": "no data for " + symbol})
+		return
+	}
+	s.replyJSON(msg, latest)
+}
+
+func (s *Server) replyJSON(msg *nats.Msg, v interface{}) {
+	data, _ := json.Marshal(v)
+	if err := msg.Respond(data); err != nil {
+		log.Printf("query.stats reply error: %v", err)
+	}
+}
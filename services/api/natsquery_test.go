@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestStatsQuery(t *testing.T) {
+	nc := runNATS(t)
+	s := &Server{
+		symbol: "btcusdt",
+		latest: map[string]ProcessedMessage{
+			"btcusdt": {Symbol: "btcusdt", Price: 42000.5, MovingAverage: 41990, Time: 1700000000120},
+			"ethusdt": {Symbol: "ethusdt", Price: 2000.25, Time: 1700000000130},
+		},
+	}
+	if _, err := nc.Subscribe("query.stats", s.handleStatsQuery); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		req   string
+		price float64 // of the reply, if no error
+		err   string
+	}{
+		{name: "active symbol", req: ``, price: 42000.5},
+		{name: "empty symbol is the active one", req: `{"symbol":""}`, price: 42000.5},
+		{name: "other symbol", req: `{"symbol":"ethusdt"}`, price: 2000.25},
+		{name: "no data", req: `{"symbol":"solusdt"}`, err: "no data for solusdt"},
+		{name: "invalid request", req: `{"symbol":`, err: "invalid request"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply, err := nc.Request("query.stats", []byte(tt.req), 2*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got struct {
+				ProcessedMessage
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal(reply.Data, &got); err != nil {
+				t.Fatalf("reply %s: %v", reply.Data, err)
+			}
+			if got.Error != tt.err || got.Price != tt.price {
+				t.Errorf("reply = %s, want price %v error %q", reply.Data, tt.price, tt.err)
+			}
+		})
+	}
+}
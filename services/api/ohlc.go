@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Intervals with a live candle kept per symbol for /api/ohlc/latest
+var ohlcIntervals = []time.Duration{
+	time.Minute, 5 * time.Minute, 15 * time.Minute, time.Hour, 4 * time.Hour, 24 * time.Hour,
+}
+
+// liveCandle is the latest bar for a symbol and interval. Closed means its
+// period has ended with no newer trade to start the next one.
+type liveCandle struct {
+	Symbol   string `json:"symbol"`
+	Interval string `json:"interval"`
+	Candle
+	Closed bool `json:"closed"`
+}
+
+// ohlcTracker keeps the forming candle per symbol for each of ohlcIntervals,
+// built from processed trades as they arrive. Periods follow loc's wall
+// clock (TIMEZONE), so the 24h candle runs from local midnight.
+type ohlcTracker struct {
+	mu   sync.RWMutex
+	loc  *time.Location
+	bars map[string][]ohlcBar // indexed like ohlcIntervals
+}
+
+type ohlcBar struct {
+	Candle
+	done bool // handed out as completed, by add or closeDue
+}
+
+func newOHLCTracker(loc *time.Location) *ohlcTracker {
+	return &ohlcTracker{loc: loc, bars: make(map[string][]ohlcBar)}
+}
+
+// add folds a trade at time t into each interval's candle, starting a new
+// one when t is past the current period. Trades older than the forming
+// candle are ignored. It returns the candles the trade completed that
+// closeDue hasn't already returned.
+func (o *ohlcTracker) add(symbol string, price, qty float64, t time.Time) []liveCandle {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	bars, ok := o.bars[symbol]
+	if !ok {
+		bars = make([]ohlcBar, len(ohlcIntervals))
+		o.bars[symbol] = bars
+	}
+	var completed []liveCandle
+	for i, d := range ohlcIntervals {
+		start := alignBucket(t, d, o.loc)
+		bar := &bars[i]
+		switch {
+		case bar.Trades == 0 || start.After(bar.Time):
+			if bar.Trades > 0 && !bar.done {
+				completed = append(completed, closedCandle(symbol, d, bar.Candle))
+			}
+			*bar = ohlcBar{Candle: Candle{Time: start, Open: price, High: price, Low: price, Close: price, Volume: qty, Trades: 1}}
+		case start.Equal(bar.Time):
+			bar.High = max(bar.High, price)
+			bar.Low = min(bar.Low, price)
+			bar.Close = price
+			bar.Volume += qty
+			bar.Trades++
+		}
+	}
+	return completed
+}
+
+// closeDue returns the candles whose period ended by cutoff, once each.
+// A trade that arrives for one afterwards still updates it, for latest,
+// but it isn't returned again.
+func (o *ohlcTracker) closeDue(cutoff time.Time) []liveCandle {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var completed []liveCandle
+	for symbol, bars := range o.bars {
+		for i, d := range ohlcIntervals {
+			bar := &bars[i]
+			if bar.Trades == 0 || bar.done || cutoff.Before(bar.Time.Add(d)) {
+				continue
+			}
+			bar.done = true
+			completed = append(completed, closedCandle(symbol, d, bar.Candle))
+		}
+	}
+	return completed
+}
+
+func closedCandle(symbol string, d time.Duration, c Candle) liveCandle {
+	return liveCandle{Symbol: symbol, Interval: intervalLabel(d), Candle: c, Closed: true}
+}
+
+// intervalLabel is the short form of an interval: 1m, 4h, 24h
+func intervalLabel(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// latest returns the current candle for symbol at interval
+func (o *ohlcTracker) latest(symbol string, interval time.Duration) (Candle, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	bars, ok := o.bars[symbol]
+	if !ok {
+		return Candle{}, false
+	}
+	for i, d := range ohlcIntervals {
+		if d == interval {
+			return bars[i].Candle, bars[i].Trades > 0
+		}
+	}
+	return Candle{}, false
+}
+
+// handleOHLCLatest returns the in-progress candle for ?symbol= (default the
+// active one) and ?interval= (1m, 5m, 15m, 1h, 4h or 24h; default 1m)
+func (s *Server) handleOHLCLatest(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	symbol := q.Get("symbol")
+	if symbol == "" {
+		s.mu.RLock()
+		symbol = s.symbol
+		s.mu.RUnlock()
+	}
+
+	interval := time.Minute
+	if v := q.Get("interval"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || !validOHLCInterval(d) {
+			writeJSONError(w, http.StatusBadRequest, "Invalid interval (1m, 5m, 15m, 1h, 4h or 24h)")
+			return
+		}
+		interval = d
+	}
+
+	bar, ok := s.ohlc.latest(symbol, interval)
+	if !ok {
+		writeJSONErrorCode(w, http.StatusNotFound, codeNoData, "No trades for "+symbol+" yet")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(liveCandle{
+		Symbol:   symbol,
+		Interval: intervalLabel(interval),
+		Candle:   bar,
+		Closed:   !s.clock.Now().Before(bar.Time.Add(interval)),
+	})
+}
+
+func validOHLCInterval(d time.Duration) bool {
+	for _, v := range ohlcIntervals {
+		if d == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Once a candle's period is over, /api/ohlc/latest returns the same payload
+// the publisher sent for it on close
+func TestOHLCLatestMatchesPublished(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	s := &Server{ohlc: newOHLCTracker(time.UTC), clock: clock, symbol: "btcusdt"}
+
+	s.ohlc.add("btcusdt", 42000, 0.5, start.Add(10*time.Second))
+	s.ohlc.add("btcusdt", 42010, 0.25, start.Add(40*time.Second))
+	clock.Set(start.Add(time.Minute))
+
+	var published []byte
+	for _, c := range s.ohlc.closeDue(clock.Now()) {
+		if c.Interval == "1m" {
+			published, _ = json.Marshal(c)
+		}
+	}
+	if published == nil {
+		t.Fatal("no 1m candle closed")
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleOHLCLatest(rec, httptest.NewRequest(http.MethodGet, "/api/ohlc/latest?interval=1m", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("latest: %d %s", rec.Code, rec.Body)
+	}
+
+	var got, want map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &got)
+	json.Unmarshal(published, &want)
+	gotJSON, _ := json.Marshal(got)
+	wantJSON, _ := json.Marshal(want)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("latest = %s\npublished = %s", gotJSON, wantJSON)
+	}
+}
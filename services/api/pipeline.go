@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// unknown marks a field the API has no signal for yet
+const unknown = "unknown"
+
+// handlePipelineStatus gathers what the API knows about every service into
+// one view: its own NATS and database connections, the last trade per
+// symbol, what ingestion announced on status.ingestion and processing's
+// warmup. Anything without a signal is reported as "unknown".
+func (s *Server) handlePipelineStatus(w http.ResponseWriter, r *http.Request) {
+	now := s.clock.Now()
+
+	var natsState interface{} = unknown
+	if s.nc != nil {
+		natsState = "disconnected"
+		if s.nc.IsConnected() {
+			natsState = "connected"
+		}
+	}
+
+	var dbState interface{} = "unavailable"
+	if s.db != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		dbState = "connected"
+		if err := s.db.Ping(ctx); err != nil {
+			dbState = "unreachable"
+		}
+		cancel()
+	}
+
+	s.mu.RLock()
+	symbol := s.symbol
+	current := s.current
+	lastTrades := make(map[string]string, len(s.latest))
+	for sym, p := range s.latest {
+		lastTrades[sym] = time.UnixMilli(p.Time).UTC().Format(time.RFC3339Nano)
+	}
+	s.mu.RUnlock()
+
+	var lastTrade interface{} = unknown
+	if len(lastTrades) > 0 {
+		lastTrade = lastTrades
+	}
+
+	var processor interface{} = unknown
+	if current.Symbol != "" && current.Symbol == symbol {
+		processor = map[string]interface{}{
+			"symbol": symbol,
+			"warmed": current.Warmed,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"nats":       natsState,
+		"database":   dbState,
+		"last_trade": lastTrade,
+		"ingestion":  announcement(&s.ingestion, now),
+		"processor":  processor,
+		"indicators": announcement(&s.indicators, now),
+	})
+}
+
+// announcement returns a status cache's last message with when it arrived
+// and whether it's stale, or "unknown" if nothing usable has arrived
+func announcement(st *statusCache, now time.Time) interface{} {
+	raw, received := st.get()
+	if raw == nil {
+		return unknown
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return unknown
+	}
+	body["updated"] = received.UTC().Format(time.RFC3339)
+	body["stale"] = now.Sub(received) > statusStaleAfter
+	return body
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// qualityTracker scores how far each symbol's latest price can be trusted,
+// from 0 to 1:
+//   - freshness: 1 while the last trade is at most gap old, falling to 0 at
+//     staleAfter
+//   - gaps: a trade arriving more than gap after the previous one halves the
+//     score, recovering linearly over recovery
+//   - warmup: halved until the processor's indicator windows are full
+//
+// Times are when the API received the trades, so a lagging exchange clock
+// doesn't count against the data.
+type qualityTracker struct {
+	gap        time.Duration // QUALITY_GAP
+	staleAfter time.Duration // QUALITY_STALE_AFTER
+	recovery   time.Duration // QUALITY_RECOVERY
+	clock      Clock
+
+	mu      sync.Mutex
+	symbols map[string]*symbolQuality
+}
+
+type symbolQuality struct {
+	lastTrade time.Time
+	gapEnded  time.Time // zero if no gap seen yet
+	warmed    bool
+}
+
+func newQualityTracker(gap, staleAfter, recovery time.Duration, clock Clock) *qualityTracker {
+	return &qualityTracker{
+		gap:        gap,
+		staleAfter: staleAfter,
+		recovery:   recovery,
+		clock:      clock,
+		symbols:    make(map[string]*symbolQuality),
+	}
+}
+
+// record notes a processed trade for symbol
+func (q *qualityTracker) record(symbol string, warmed bool) {
+	now := q.clock.Now()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	sq, ok := q.symbols[symbol]
+	if !ok {
+		sq = &symbolQuality{}
+		q.symbols[symbol] = sq
+	}
+	if !sq.lastTrade.IsZero() && now.Sub(sq.lastTrade) > q.gap {
+		sq.gapEnded = now
+	}
+	sq.lastTrade = now
+	sq.warmed = warmed
+}
+
+// score returns symbol's current quality, rounded to two decimals; 0 before
+// its first trade
+func (q *qualityTracker) score(symbol string) float64 {
+	now := q.clock.Now()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	sq, ok := q.symbols[symbol]
+	if !ok {
+		return 0
+	}
+
+	score := 1.0
+	if age := now.Sub(sq.lastTrade); age >= q.staleAfter {
+		return 0
+	} else if age > q.gap {
+		score = 1 - float64(age-q.gap)/float64(q.staleAfter-q.gap)
+	}
+	if !sq.gapEnded.IsZero() && q.recovery > 0 {
+		if since := now.Sub(sq.gapEnded); since < q.recovery {
+			score *= 0.5 + 0.5*float64(since)/float64(q.recovery)
+		}
+	}
+	if !sq.warmed {
+		score *= 0.5
+	}
+	return math.Round(score*100) / 100
+}
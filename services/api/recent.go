@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Upper bound on RECENT_SIZE so a typo can't eat the heap
+const maxRecentSize = 100000
+
+// RecentPrice is one entry in a symbol's in-memory ring
+type RecentPrice struct {
+	Price float64 `json:"price"`
+	Time  int64   `json:"time"`
+}
+
+// priceRing is a fixed-size circular buffer, oldest overwritten first
+type priceRing struct {
+	buf  []RecentPrice
+	next int
+	full bool
+	seq  uint64 // prices ever added, numbering each one
+}
+
+// recentPrices keeps the last N prices per symbol without touching the DB
+type recentPrices struct {
+	mu    sync.RWMutex
+	size  int
+	rings map[string]*priceRing
+}
+
+func newRecentPrices(size int) *recentPrices {
+	return &recentPrices{size: size, rings: make(map[string]*priceRing)}
+}
+
+// add appends a price for symbol and returns its sequence number, which
+// counts up from 1 per symbol
+func (r *recentPrices) add(symbol string, price float64, t int64) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ring, ok := r.rings[symbol]
+	if !ok {
+		ring = &priceRing{buf: make([]RecentPrice, r.size)}
+		r.rings[symbol] = ring
+	}
+	ring.buf[ring.next] = RecentPrice{Price: price, Time: t}
+	ring.next = (ring.next + 1) % r.size
+	if ring.next == 0 {
+		ring.full = true
+	}
+	ring.seq++
+	return ring.seq
+}
+
+// last returns up to n prices for symbol, oldest first
+func (r *recentPrices) last(symbol string, n int) []RecentPrice {
+	prices, _ := r.lastSeq(symbol, n)
+	return prices
+}
+
+// lastSeq is last, plus the sequence number of the newest price (0 before
+// the first)
+func (r *recentPrices) lastSeq(symbol string, n int) ([]RecentPrice, uint64) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ring, ok := r.rings[symbol]
+	if !ok {
+		return []RecentPrice{}, 0
+	}
+	count := ring.next
+	if ring.full {
+		count = r.size
+	}
+	if n > count {
+		n = count
+	}
+
+	out := make([]RecentPrice, n)
+	start := ring.next - n
+	if start < 0 {
+		start += r.size
+	}
+	for i := range out {
+		out[i] = ring.buf[(start+i)%r.size]
+	}
+	return out, ring.seq
+}
+
+func (s *Server) handleRecent(w http.ResponseWriter, r *http.Request) {
+	symbol := r.URL.Query().Get("symbol")
+	if symbol == "" {
+		s.mu.RLock()
+		symbol = s.symbol
+		s.mu.RUnlock()
+	}
+
+	n := s.recent.size
+	if v := r.URL.Query().Get("n"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid n")
+			return
+		}
+		n = parsed
+	}
+
+	prices := s.recent.last(symbol, n)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"symbol": symbol,
+		"prices": prices,
+	})
+}
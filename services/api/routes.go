@@ -0,0 +1,37 @@
+package main
+
+import "net/http"
+
+// Handler returns the API's routes mounted under base. Each call builds its
+// own mux, so several servers can run in one process without colliding on
+// http.DefaultServeMux.
+func (s *Server) Handler(base, adminToken string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(base+"/api/price", s.handlePrice)
+	mux.HandleFunc(base+"/api/stats", s.handleStats)
+	mux.HandleFunc(base+"/api/stats/multi", s.handleStatsMulti)
+	mux.HandleFunc(base+"/api/indicators", s.handleIndicators)
+	mux.HandleFunc(base+"/api/history", withGzip(s.handleHistory))
+	mux.HandleFunc(base+"/api/candles", withGzip(s.handleCandles))
+	mux.HandleFunc(base+"/api/ohlc/latest", s.handleOHLCLatest)
+	mux.HandleFunc(base+"/api/symbol", s.handleSymbol)
+	mux.HandleFunc(base+"/api/symbol/stats", s.handleSymbolStats)
+	mux.HandleFunc(base+"/api/symbols", requireAdmin(adminToken, s.handleSymbols))
+	mux.HandleFunc(base+"/api/coins", withGzip(s.handleCoins))
+	mux.HandleFunc(base+"/api/book", s.handleBook)
+	mux.HandleFunc(base+"/api/depth", withGzip(s.handleDepth))
+	mux.HandleFunc(base+"/api/recent", withGzip(s.handleRecent))
+	mux.HandleFunc(base+"/api/correlation", s.handleCorrelation)
+	mux.HandleFunc(base+"/api/backtest", s.handleBacktest)
+	mux.HandleFunc(base+"/api/stream", s.handleStream)
+	mux.HandleFunc(base+"/api/metrics", s.handleMetrics)
+	mux.HandleFunc(base+"/api/status", s.handleStatus)
+	mux.HandleFunc(base+"/api/pipeline/status", s.handlePipelineStatus)
+	mux.HandleFunc(base+"/api/version", handleVersion)
+	mux.HandleFunc(base+"/healthz", handleHealthz)
+	mux.HandleFunc(base+"/readyz", s.handleReadyz)
+	mux.HandleFunc(base+"/ws", s.handleWebSocket)
+	mux.HandleFunc(base+"/api/admin/reset", requireAdmin(adminToken, s.handleAdminReset))
+	mux.HandleFunc(base+"/api/admin/clients", requireAdmin(adminToken, s.handleAdminClients))
+	return mux
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// sseReplaySize is how many recent events are kept for Last-Event-ID resumption
+const sseReplaySize = 256
+
+type sseEvent struct {
+	id   uint64
+	data []byte
+}
+
+// sseBroker fans processed updates out to Server-Sent Events clients
+type sseBroker struct {
+	mu      sync.Mutex
+	nextID  uint64
+	recent  []sseEvent
+	clients map[chan sseEvent]struct{}
+}
+
+func newSSEBroker() *sseBroker {
+	return &sseBroker{
+		clients: make(map[chan sseEvent]struct{}),
+	}
+}
+
+// publish assigns the next event ID and sends to every client, dropping
+// the event for clients whose buffer is full rather than blocking
+func (b *sseBroker) publish(data []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	ev := sseEvent{id: b.nextID, data: data}
+
+	b.recent = append(b.recent, ev)
+	if len(b.recent) > sseReplaySize {
+		b.recent = b.recent[1:]
+	}
+
+	for ch := range b.clients {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// subscribe registers a client and returns any buffered events newer than lastID
+func (b *sseBroker) subscribe(lastID uint64) (chan sseEvent, []sseEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan sseEvent, 64)
+	b.clients[ch] = struct{}{}
+
+	var missed []sseEvent
+	if lastID > 0 {
+		for _, ev := range b.recent {
+			if ev.id > lastID {
+				missed = append(missed, ev)
+			}
+		}
+	}
+	return ch, missed
+}
+
+func (b *sseBroker) unsubscribe(ch chan sseEvent) {
+	b.mu.Lock()
+	delete(b.clients, ch)
+	b.mu.Unlock()
+}
+
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	lastID, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+	ch, missed := s.sse.subscribe(lastID)
+	defer s.sse.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // disable proxy buffering (nginx)
+	w.WriteHeader(http.StatusOK)
+
+	for _, ev := range missed {
+		fmt.Fprintf(w, "id: %d\ndata: %s\n\n", ev.id, ev.data)
+	}
+	flusher.Flush()
+
+	// Comment lines keep idle connections open through proxies
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-ch:
+			fmt.Fprintf(w, "id: %d\ndata: %s\n\n", ev.id, ev.data)
+			flusher.Flush()
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// sseUpdate is the payload pushed to /api/stream clients
+func sseUpdate(p ProcessedMessage) []byte {
+	data, _ := json.Marshal(p)
+	return data
+}
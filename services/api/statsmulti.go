@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bounds for /api/stats/multi so one request can't fan out into heavy scans
+const (
+	maxStatsWindows = 6
+	minStatsWindow  = time.Minute
+	maxStatsWindow  = 7 * 24 * time.Hour
+)
+
+// WindowStats summarizes one symbol's trades over a lookback window. The
+// pointers are null when the window holds no trades.
+type WindowStats struct {
+	Average *float64 `json:"average"`
+	High    *float64 `json:"high"`
+	Low     *float64 `json:"low"`
+	Change  *float64 `json:"change"`
+	Trades  int      `json:"trades"`
+}
+
+// handleStatsMulti aggregates several windows (?windows=5m,1h,24h) in one
+// call, running the queries concurrently
+func (s *Server) handleStatsMulti(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSONErrorCode(w, http.StatusServiceUnavailable, codeDBUnavailable, "Database not available")
+		return
+	}
+
+	q := r.URL.Query()
+	symbol := q.Get("symbol")
+	if symbol == "" {
+		s.mu.RLock()
+		symbol = s.symbol
+		s.mu.RUnlock()
+	}
+
+	raw := q.Get("windows")
+	if raw == "" {
+		raw = "5m,1h,24h"
+	}
+	windows := make(map[string]time.Duration)
+	for _, v := range strings.Split(raw, ",") {
+		v = strings.TrimSpace(v)
+		d, err := time.ParseDuration(v)
+		if err != nil || d < minStatsWindow || d > maxStatsWindow {
+			writeJSONError(w, http.StatusBadRequest, "Invalid window "+v+" (1m to 168h)")
+			return
+		}
+		windows[v] = d
+	}
+	if len(windows) > maxStatsWindows {
+		writeJSONError(w, http.StatusBadRequest, "Too many windows (max 6)")
+		return
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results = make(map[string]WindowStats, len(windows))
+		failed  bool
+	)
+	for name, d := range windows {
+		wg.Add(1)
+		go func(name string, d time.Duration) {
+			defer wg.Done()
+			st, err := s.windowStats(r.Context(), symbol, d)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed = true
+				return
+			}
+			results[name] = st
+		}(name, d)
+	}
+	wg.Wait()
+
+	if failed {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to compute stats")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"symbol":  symbol,
+		"windows": results,
+	})
+}
+
+func (s *Server) windowStats(ctx context.Context, symbol string, window time.Duration) (WindowStats, error) {
+	var st WindowStats
+	err := s.db.QueryRow(ctx, `
+		SELECT avg(price), max(price), min(price),
+			last(price, time) - first(price, time), count(*)
+		FROM trades WHERE symbol = $1 AND time > now() - $2::interval`,
+		symbol, window).Scan(&st.Average, &st.High, &st.Low, &st.Change, &st.Trades)
+	return st, err
+}
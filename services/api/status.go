@@ -0,0 +1,25 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// handleStatus reports the selected symbol and the last processed trade,
+// including its trace_id for following it back through the pipeline
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	current := s.current
+	symbol := s.symbol
+	s.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"symbol": symbol,
+		"last_trade": map[string]interface{}{
+			"trace_id": current.TraceID,
+			"price":    current.Price,
+			"time":     current.Time,
+		},
+	})
+}
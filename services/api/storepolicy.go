@@ -0,0 +1,41 @@
+package main
+
+import "sync"
+
+// storePolicy decides which processed trades are persisted. Every trade is
+// stored unless MIN_PRICE_DELTA or STORE_SAMPLE_RATE is set; with both set,
+// a trade is stored if either condition passes.
+type storePolicy struct {
+	delta      *deltaFilter // nil when MIN_PRICE_DELTA is unset
+	sampleRate int          // store 1 in N per symbol; <= 1 disables
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newStorePolicy(delta *deltaFilter, sampleRate int) *storePolicy {
+	return &storePolicy{delta: delta, sampleRate: sampleRate, counts: make(map[string]int)}
+}
+
+// allow reports whether this trade should be written to the database
+func (p *storePolicy) allow(symbol string, price float64) bool {
+	sampling := p.sampleRate > 1
+	if p.delta == nil && !sampling {
+		return true
+	}
+
+	ok := false
+	if sampling {
+		p.mu.Lock()
+		ok = p.counts[symbol]%p.sampleRate == 0
+		p.counts[symbol]++
+		p.mu.Unlock()
+	}
+	if p.delta != nil {
+		ok = ok || p.delta.moved(symbol, price)
+		if ok {
+			p.delta.stored(symbol, price)
+		}
+	}
+	return ok
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+)
+
+// maxTrackedSymbols bounds PUT /api/symbols; ingestion opens a connection
+// per symbol, and Binance limits new connections per IP
+const maxTrackedSymbols = 50
+
+// handleSymbols replaces the whole set of symbols ingestion streams besides
+// the active one, in one control.symbols message. Symbols are validated
+// and normalized like POST /api/symbol; an empty list stops all but the
+// active symbol. Processing only handles the active symbol, so each one in
+// the response says whether it's processed or only ingested.
+func (s *Server) handleSymbols(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSONError(w, http.StatusMethodNotAllowed, "PUT required")
+		return
+	}
+
+	var req struct {
+		Symbols []string `json:"symbols"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if len(req.Symbols) > maxTrackedSymbols {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("At most %d symbols", maxTrackedSymbols))
+		return
+	}
+
+	s.mu.RLock()
+	active := s.symbol
+	s.mu.RUnlock()
+
+	symbols := make([]string, 0, len(req.Symbols))
+	infos := make([]map[string]interface{}, 0, len(req.Symbols))
+	for _, raw := range req.Symbols {
+		symbol := s.aliases.Normalize(raw)
+		name := getCoinName(symbol)
+		if name == symbol {
+			writeJSONErrorCode(w, http.StatusBadRequest, codeUnknownSymbol, fmt.Sprintf("Unknown symbol %q", raw))
+			return
+		}
+		if slices.Contains(symbols, symbol) {
+			continue
+		}
+		symbols = append(symbols, symbol)
+		info := symbolInfo(symbol, s.enrichedName(symbol, name))
+		info["processed"] = symbol == active
+		infos = append(infos, info)
+	}
+
+	msg, _ := json.Marshal(map[string][]string{"symbols": symbols})
+	if err := s.nc.Publish("control.symbols", msg); err != nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "Failed to publish symbols")
+		return
+	}
+	log.Printf("Tracked symbols set to %v", symbols)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"symbols": infos})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPutSymbols(t *testing.T) {
+	nc := runNATS(t)
+	sub, err := nc.SubscribeSync("control.symbols")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nc.Flush()
+	s := &Server{nc: nc, symbol: "btcusdt"}
+	h := s.Handler("", "secret")
+
+	put := func(auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/symbols", strings.NewReader(`{"symbols":["btcusdt","ethusdt"]}`))
+		if auth != "" {
+			req.Header.Set("Authorization", "Bearer "+auth)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, auth := range []string{"", "wrong"} {
+		if rec := put(auth); rec.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: %d, want 401", auth, rec.Code)
+		}
+	}
+	if _, err := sub.NextMsg(50 * time.Millisecond); err == nil {
+		t.Fatal("unauthorized request published control.symbols")
+	}
+
+	rec := put("secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("authorized: %d %s", rec.Code, rec.Body)
+	}
+	if _, err := sub.NextMsg(time.Second); err != nil {
+		t.Fatalf("control.symbols not published: %v", err)
+	}
+
+	// Only the active symbol is processed
+	var resp struct {
+		Symbols []struct {
+			Symbol    string `json:"symbol"`
+			Processed bool   `json:"processed"`
+		} `json:"symbols"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"btcusdt": true, "ethusdt": false}
+	if len(resp.Symbols) != len(want) {
+		t.Fatalf("got %d symbols, want %d", len(resp.Symbols), len(want))
+	}
+	for _, sym := range resp.Symbols {
+		if sym.Processed != want[sym.Symbol] {
+			t.Errorf("%s processed = %v, want %v", sym.Symbol, sym.Processed, want[sym.Symbol])
+		}
+	}
+}
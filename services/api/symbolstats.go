@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// symbolStatsTimeout bounds the database half of /api/symbol/stats; the
+// live half is always returned
+const symbolStatsTimeout = 3 * time.Second
+
+// handleSymbolStats returns everything the dashboard summarizes for a
+// symbol (?symbol=, default the active one) in one response: live stats
+// since processing started, the last 24h from the database, and the 24h
+// change in percent. The two database queries run concurrently. If the
+// database is unavailable or slow, "day" and "change_24h_percent" are
+// null rather than failing the request.
+func (s *Server) handleSymbolStats(w http.ResponseWriter, r *http.Request) {
+	symbol := s.aliases.Normalize(r.URL.Query().Get("symbol"))
+
+	s.mu.RLock()
+	if symbol == "" {
+		symbol = s.symbol
+	}
+	latest, ok := s.latest[symbol]
+	s.mu.RUnlock()
+
+	name := getCoinName(symbol)
+	if name == symbol {
+		writeJSONErrorCode(w, http.StatusNotFound, codeUnknownSymbol, "Unknown symbol")
+		return
+	}
+
+	var live map[string]interface{}
+	if ok {
+		live = map[string]interface{}{
+			"price":          latest.Price,
+			"moving_average": latest.MovingAverage,
+			"high":           latest.High,
+			"low":            latest.Low,
+			"rolling_high":   latest.RollingHigh,
+			"rolling_low":    latest.RollingLow,
+			"warmed":         latest.Warmed,
+			"time":           latest.Time,
+		}
+	}
+
+	var (
+		day       *WindowStats
+		changePct *float64
+	)
+	if s.db != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), symbolStatsTimeout)
+		defer cancel()
+
+		var (
+			wg              sync.WaitGroup
+			st              WindowStats
+			open            float64
+			statsErr, opErr error
+		)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			st, statsErr = s.windowStats(ctx, symbol, 24*time.Hour)
+		}()
+		go func() {
+			defer wg.Done()
+			open, opErr = s.openPrice(ctx, symbol, 24*time.Hour)
+		}()
+		wg.Wait()
+
+		if statsErr != nil {
+			log.Printf("Symbol stats query error for %s: %v", symbol, statsErr)
+		} else {
+			day = &st
+		}
+		switch {
+		case errors.Is(opErr, pgx.ErrNoRows):
+		case opErr != nil:
+			log.Printf("Symbol stats query error for %s: %v", symbol, opErr)
+		case day != nil && day.Change != nil && open != 0:
+			pct := *day.Change / open * 100
+			changePct = &pct
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"symbol":             symbol,
+		"name":               s.enrichedName(symbol, name),
+		"live":               live,
+		"day":                day,
+		"change_24h_percent": changePct,
+	})
+}
+
+// openPrice is the first price stored for symbol within window, the base
+// the window's change is measured from
+func (s *Server) openPrice(ctx context.Context, symbol string, window time.Duration) (float64, error) {
+	var price float64
+	err := s.db.QueryRow(ctx, `
+		SELECT price FROM trades
+		WHERE symbol = $1 AND time > now() - $2::interval
+		ORDER BY `+s.timeOrder(`ASC`)+` LIMIT 1`,
+		symbol, window).Scan(&price)
+	return price, err
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"time"
+
+	// The runtime image has no zoneinfo; embed it so TIMEZONE always loads
+	_ "time/tzdata"
+)
+
+// loadTimezone loads TIMEZONE (an IANA name such as America/New_York).
+// Empty means UTC.
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// alignBucket returns the start of the d-long bucket holding t, with
+// buckets aligned to loc's wall clock: daily buckets start at local
+// midnight, hourly ones on the local hour (which differs from UTC in
+// half-hour zones).
+func alignBucket(t time.Time, d time.Duration, loc *time.Location) time.Time {
+	_, offset := t.In(loc).Zone()
+	start := t.Add(seconds(offset)).Truncate(d).Add(-seconds(offset))
+	// Across a DST change the bucket started under the other offset
+	if _, startOffset := start.In(loc).Zone(); startOffset != offset {
+		start = start.Add(seconds(offset - startOffset))
+	}
+	return start.In(loc)
+}
+
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
+}
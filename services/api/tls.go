@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// defaultListenAddr is the listen address when neither HTTP_ADDR nor -port
+// sets one: :443 with Let's Encrypt, where its TLS-ALPN-01 challenges
+// arrive, and :8080 otherwise
+func defaultListenAddr() string {
+	if os.Getenv("AUTO_TLS_DOMAIN") != "" {
+		return ":443"
+	}
+	return ":8080"
+}
+
+// listenAndServe picks plain HTTP, static TLS (TLS_CERT_FILE/TLS_KEY_FILE)
+// or Let's Encrypt (AUTO_TLS_DOMAIN) based on the environment, serving on
+// srv.Addr in every case
+func listenAndServe(srv *http.Server) error {
+	certFile := os.Getenv("TLS_CERT_FILE")
+	keyFile := os.Getenv("TLS_KEY_FILE")
+
+	if domains := os.Getenv("AUTO_TLS_DOMAIN"); domains != "" {
+		cacheDir := os.Getenv("AUTO_TLS_CACHE")
+		if cacheDir == "" {
+			cacheDir = "certs"
+		}
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(strings.Split(domains, ",")...),
+			Cache:      autocert.DirCache(cacheDir),
+		}
+
+		// ACME_HTTP_ADDR answers HTTP-01 challenges and redirects everything
+		// else to HTTPS. Let's Encrypt always connects to port 80, so any
+		// other address has to be forwarded from it. Without this listener
+		// certificates can't be issued or renewed, so failing to bind it
+		// fails startup.
+		challengeAddr := os.Getenv("ACME_HTTP_ADDR")
+		if challengeAddr == "" {
+			challengeAddr = ":80"
+		}
+		if challengeAddr == srv.Addr {
+			return fmt.Errorf("ACME_HTTP_ADDR and the HTTPS listen address are both %s", srv.Addr)
+		}
+		ln, err := net.Listen("tcp", challengeAddr)
+		if err != nil {
+			return fmt.Errorf("ACME challenge listener: %w", err)
+		}
+		go func() {
+			if err := http.Serve(ln, m.HTTPHandler(nil)); err != nil {
+				log.Fatalf("ACME challenge listener on %s failed: %v", challengeAddr, err)
+			}
+		}()
+
+		srv.TLSConfig = m.TLSConfig()
+		log.Printf("Serving HTTPS for %s via Let's Encrypt on %s (challenges on %s)", domains, srv.Addr, challengeAddr)
+		return srv.ListenAndServeTLS("", "")
+	}
+
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			log.Fatal("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+		}
+		log.Printf("Serving HTTPS on %s", srv.Addr)
+		return srv.ListenAndServeTLS(certFile, keyFile)
+	}
+
+	return srv.ListenAndServe()
+}
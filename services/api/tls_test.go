@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDefaultListenAddr(t *testing.T) {
+	t.Setenv("AUTO_TLS_DOMAIN", "")
+	if got := defaultListenAddr(); got != ":8080" {
+		t.Errorf("plain HTTP: %s, want :8080", got)
+	}
+	t.Setenv("AUTO_TLS_DOMAIN", "example.com")
+	if got := defaultListenAddr(); got != ":443" {
+		t.Errorf("Let's Encrypt: %s, want :443", got)
+	}
+}
+
+// Without the challenge listener certificates can't be issued, so not
+// getting it must stop startup rather than be logged
+func TestAutoTLSChallengeListenerFails(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+
+	t.Setenv("AUTO_TLS_DOMAIN", "example.com")
+	t.Setenv("AUTO_TLS_CACHE", t.TempDir())
+
+	t.Setenv("ACME_HTTP_ADDR", busy.Addr().String())
+	err = listenAndServe(&http.Server{Addr: "127.0.0.1:0"})
+	if err == nil || !strings.Contains(err.Error(), "ACME challenge listener") {
+		t.Errorf("address in use: %v", err)
+	}
+
+	t.Setenv("ACME_HTTP_ADDR", ":8443")
+	err = listenAndServe(&http.Server{Addr: ":8443"})
+	if err == nil || !strings.Contains(err.Error(), "both :8443") {
+		t.Errorf("same address as HTTPS: %v", err)
+	}
+}
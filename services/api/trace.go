@@ -0,0 +1,16 @@
+package main
+
+import (
+	"log"
+	"os"
+)
+
+// traceLog turns on one log line per trade at each hop (TRACE_LOG=true),
+// keyed by trace_id so a single trade can be followed through the pipeline
+var traceLog = os.Getenv("TRACE_LOG") == "true"
+
+func logTrace(hop, traceID, symbol string, price float64) {
+	if traceLog {
+		log.Printf("trace_id=%s hop=%s symbol=%s price=%.8g", traceID, hop, symbol, price)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TradeLog appends processed trades to a JSONL file. The active file is
+// rotated to <name>-YYYYMMDDHH<ext> when the hour changes.
+//
+// Lines are buffered and written out every flush interval, or straight
+// away with an interval of 0. A crash loses what's still buffered. With
+// fsync, each flush also waits for the file to reach the disk, so a power
+// loss can't take flushed lines either; that costs a disk round trip per
+// flush, which with an interval of 0 is one per trade.
+type TradeLog struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	buf      *bufio.Writer
+	hour     time.Time
+	clock    Clock
+	openFile func(path string) (*os.File, error)
+
+	interval time.Duration // 0 flushes on every write
+	fsync    bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewTradeLog opens (or creates) the log at path and, for a non-zero
+// flushInterval, starts a background flusher so buffered lines reach the
+// file within it. Hours are read from clock.
+func NewTradeLog(path string, clock Clock, flushInterval time.Duration, fsync bool) (*TradeLog, error) {
+	l := &TradeLog{
+		path:     path,
+		clock:    clock,
+		openFile: openAppend,
+		interval: flushInterval,
+		fsync:    fsync,
+		done:     make(chan struct{}),
+	}
+
+	// Rotate away a file left over from an earlier hour
+	if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+		if hour := info.ModTime().Truncate(time.Hour); !hour.Equal(clock.Now().Truncate(time.Hour)) {
+			os.Rename(path, l.rotatedName(hour))
+		}
+	}
+
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+
+	if l.interval > 0 {
+		l.wg.Add(1)
+		go l.flushLoop(l.interval)
+	}
+	return l, nil
+}
+
+func openAppend(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+}
+
+func (l *TradeLog) open() error {
+	f, err := l.openFile(l.path)
+	if err != nil {
+		return err
+	}
+	l.file = f
+	l.buf = bufio.NewWriterSize(f, 64*1024)
+	l.hour = l.clock.Now().Truncate(time.Hour)
+	return nil
+}
+
+func (l *TradeLog) rotatedName(hour time.Time) string {
+	ext := filepath.Ext(l.path)
+	return strings.TrimSuffix(l.path, ext) + "-" + hour.Format("2006010215") + ext
+}
+
+// flush writes out buffered lines, and with fsync waits for them to reach
+// the disk. Caller must hold l.mu.
+func (l *TradeLog) flush() error {
+	if err := l.buf.Flush(); err != nil {
+		return err
+	}
+	if l.fsync {
+		return l.file.Sync()
+	}
+	return nil
+}
+
+// rotate renames the current file after its hour and opens a fresh one.
+// Whatever fails, the current file stays open: a failed flush leaves it in
+// place to retry on the next write, and a failed rename or open keeps
+// appending to it into the new hour, to retry at the next one. If the
+// fresh file can't be opened, the renamed one is moved back so the open
+// file is the one at path again. Caller must hold l.mu.
+func (l *TradeLog) rotate() error {
+	if err := l.flush(); err != nil {
+		return err
+	}
+	rotated := l.rotatedName(l.hour)
+	if err := os.Rename(l.path, rotated); err != nil {
+		l.hour = l.clock.Now().Truncate(time.Hour)
+		return err
+	}
+	old := l.file
+	if err := l.open(); err != nil {
+		l.hour = l.clock.Now().Truncate(time.Hour)
+		return errors.Join(err, os.Rename(rotated, l.path))
+	}
+	return old.Close()
+}
+
+// Write appends msg as a single JSON line. If rotation fails the line still
+// goes into the current file, and the rotation error is returned.
+func (l *TradeLog) Write(msg ProcessedMessage) error {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return os.ErrClosed
+	}
+	var rotateErr error
+	if now := l.clock.Now().Truncate(time.Hour); !now.Equal(l.hour) {
+		rotateErr = l.rotate()
+	}
+
+	l.buf.Write(line)
+	if err := l.buf.WriteByte('\n'); err != nil {
+		return err
+	}
+	if l.interval == 0 {
+		if err := l.flush(); err != nil {
+			return err
+		}
+	}
+	return rotateErr
+}
+
+func (l *TradeLog) flushLoop(interval time.Duration) {
+	defer l.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.mu.Lock()
+			if l.buf != nil {
+				l.flush()
+			}
+			l.mu.Unlock()
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// Close flushes any buffered lines and closes the file
+func (l *TradeLog) Close() error {
+	close(l.done)
+	l.wg.Wait()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.flush()
+	if cerr := l.file.Close(); err == nil {
+		err = cerr
+	}
+	l.file = nil
+	l.buf = nil
+	return err
+}
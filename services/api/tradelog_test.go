@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lines reads path's JSONL lines, failing the test if it can't
+func lines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Fields(string(data))
+}
+
+func TestTradeLogRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trades.jsonl")
+	clock := newFakeClock(time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC))
+	l, err := NewTradeLog(path, clock, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if err := l.Write(ProcessedMessage{Symbol: "btcusdt", Price: 1}); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	if err := l.Write(ProcessedMessage{Symbol: "btcusdt", Price: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := lines(t, strings.TrimSuffix(path, ".jsonl")+"-2024030110.jsonl")
+	if len(rotated) != 1 || !strings.Contains(rotated[0], `"price":1`) {
+		t.Errorf("rotated file = %q", rotated)
+	}
+	current := lines(t, path)
+	if len(current) != 1 || !strings.Contains(current[0], `"price":2`) {
+		t.Errorf("current file = %q", current)
+	}
+}
+
+// When the rotated name can't be used, lines keep going into the current
+// file, and rotation is retried at the next hour rather than every write
+func TestTradeLogRenameFails(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "trades.jsonl")
+	clock := newFakeClock(time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC))
+	l, err := NewTradeLog(path, clock, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// A non-empty directory can't be renamed over
+	blocker := filepath.Join(dir, "trades-2024030110.jsonl")
+	if err := os.MkdirAll(filepath.Join(blocker, "x"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Write(ProcessedMessage{Symbol: "btcusdt", Price: 1}); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	if err := l.Write(ProcessedMessage{Symbol: "btcusdt", Price: 2}); err == nil {
+		t.Error("Write returned no error for a failed rotation")
+	}
+	if err := l.Write(ProcessedMessage{Symbol: "btcusdt", Price: 3}); err != nil {
+		t.Errorf("rotation retried within the hour: %v", err)
+	}
+	if got := lines(t, path); len(got) != 3 {
+		t.Fatalf("current file has %d lines, want all 3: %q", len(got), got)
+	}
+
+	// The next hour rotates everything so far under that hour's name
+	clock.Advance(time.Hour)
+	if err := l.Write(ProcessedMessage{Symbol: "btcusdt", Price: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if got := lines(t, filepath.Join(dir, "trades-2024030111.jsonl")); len(got) != 3 {
+		t.Errorf("rotated file has %d lines, want 3", len(got))
+	}
+	if got := lines(t, path); len(got) != 1 || !strings.Contains(got[0], `"price":4`) {
+		t.Errorf("current file = %q", got)
+	}
+}
+
+// When the fresh file can't be opened, the renamed one goes back to path,
+// so lines written meanwhile don't end up in the archive
+func TestTradeLogOpenFails(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "trades.jsonl")
+	clock := newFakeClock(time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC))
+	l, err := NewTradeLog(path, clock, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if err := l.Write(ProcessedMessage{Symbol: "btcusdt", Price: 1}); err != nil {
+		t.Fatal(err)
+	}
+	l.openFile = func(string) (*os.File, error) { return nil, os.ErrPermission }
+	clock.Advance(time.Hour)
+	if err := l.Write(ProcessedMessage{Symbol: "btcusdt", Price: 2}); err == nil {
+		t.Error("Write returned no error for a failed rotation")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "trades-2024030110.jsonl")); !os.IsNotExist(err) {
+		t.Errorf("rotated file left behind: %v", err)
+	}
+	if got := lines(t, path); len(got) != 2 {
+		t.Fatalf("current file has %d lines, want both: %q", len(got), got)
+	}
+
+	// Once the file opens again, the next hour rotates both lines
+	l.openFile = openAppend
+	clock.Advance(time.Hour)
+	if err := l.Write(ProcessedMessage{Symbol: "btcusdt", Price: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if got := lines(t, filepath.Join(dir, "trades-2024030111.jsonl")); len(got) != 2 {
+		t.Errorf("rotated file has %d lines, want 2", len(got))
+	}
+	if got := lines(t, path); len(got) != 1 || !strings.Contains(got[0], `"price":3`) {
+		t.Errorf("current file = %q", got)
+	}
+}
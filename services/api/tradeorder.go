@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Timestamps stored for trades (STORE_TIME)
+const (
+	storeTimeTrade    = "trade"    // the trade's own time from the exchange
+	storeTimeReceived = "received" // when the API received it
+)
+
+func parseStoreTime(v string) (string, error) {
+	switch v {
+	case "":
+		return storeTimeTrade, nil
+	case storeTimeTrade, storeTimeReceived:
+		return v, nil
+	}
+	return "", fmt.Errorf("unknown STORE_TIME %q (trade or received)", v)
+}
+
+// storedTime is the time to store a trade under: its own time with
+// STORE_TIME=trade, falling back to received for trades without one
+func storedTime(mode string, tradeMs int64, received time.Time) time.Time {
+	if mode == storeTimeTrade && tradeMs > 0 {
+		return time.UnixMilli(tradeMs)
+	}
+	return received
+}
+
+// addTradeIDColumn gives trades an id in insert order, which breaks ties
+// between trades stored under the same millisecond. Rows already there
+// are numbered as part of the migration. The column is checked for first:
+// ADD COLUMN IF NOT EXISTS with a serial type can still create a sequence.
+func addTradeIDColumn(db *pgxpool.Pool) error {
+	ctx := context.Background()
+	var exists bool
+	err := db.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_name = 'trades' AND column_name = 'id'
+		)`).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+	_, err = db.Exec(ctx, `ALTER TABLE trades ADD COLUMN id BIGSERIAL`)
+	return err
+}
+
+// timeOrder is the ORDER BY for trades by time in dir (ASC or DESC), with
+// the id as tiebreaker when trades has one, so equal timestamps always come
+// back in the order they were stored
+func (s *Server) timeOrder(dir string) string {
+	if !s.tradeIDs {
+		return `time ` + dir
+	}
+	return `time ` + dir + `, id ` + dir
+}
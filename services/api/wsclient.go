@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// wsClient is what the server tracks about a connected WebSocket client,
+// for /api/admin/clients
+type wsClient struct {
+	remoteAddr  string
+	connectedAt time.Time
+
+	send chan []byte // outbound queue, drained by the Hub's writer
+
+	// Set by a subscribe message; guarded by the Hub's lock. Without one
+	// the client follows the active symbol.
+	symbol string
+	after  uint64 // last price sequence number sent in the history batch
+
+	sent      atomic.Int64 // messages written
+	dropped   atomic.Int64 // messages discarded because send was full
+	lastWrite atomic.Int64 // duration of the most recent write, in ns
+	lastSent  atomic.Int64 // when the last message went out (or connect), unix ns
+}
+
+// enqueue queues msg without blocking, dropping it if the queue is full
+func (c *wsClient) enqueue(msg []byte) {
+	select {
+	case c.send <- msg:
+	default:
+		c.dropped.Add(1)
+		metrics.Add("ws_dropped", 1)
+	}
+}
+
+// wsClientInfo is one client in the /api/admin/clients response. A slow
+// client shows up as a long last_write_ms, a backed-up queue, and then
+// dropped messages.
+type wsClientInfo struct {
+	RemoteAddr   string    `json:"remote_addr"`
+	ConnectedAt  time.Time `json:"connected_at"`
+	Symbols      []string  `json:"symbols"`
+	MessagesSent int64     `json:"messages_sent"`
+	Dropped      int64     `json:"dropped"`
+	Queued       int       `json:"queued"`
+	LastWriteMs  float64   `json:"last_write_ms"`
+}
+
+func (c *wsClient) info(symbols []string) wsClientInfo {
+	if c.symbol != "" {
+		symbols = []string{c.symbol}
+	}
+	return wsClientInfo{
+		RemoteAddr:   c.remoteAddr,
+		ConnectedAt:  c.connectedAt,
+		Symbols:      symbols,
+		MessagesSent: c.sent.Load(),
+		Dropped:      c.dropped.Load(),
+		Queued:       len(c.send),
+		LastWriteMs:  float64(c.lastWrite.Load()) / float64(time.Millisecond),
+	}
+}
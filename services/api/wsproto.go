@@ -0,0 +1,27 @@
+package main
+
+import "strconv"
+
+// wsProtocolVersion is sent in every /ws message. Bump it when an existing
+// message type changes shape; adding a new type doesn't need a bump, since
+// clients skip types they don't know.
+const wsProtocolVersion = 1
+
+// WebSocket message types, the envelope's "type" field
+const (
+	wsTypePrice     = "price"
+	wsTypeHeartbeat = "heartbeat"
+	wsTypeCrossover = "crossover"
+	wsTypeHistory   = "history"
+	wsTypeError     = "error"
+)
+
+// appendEnvelope starts a /ws message: {"version":1,"type":typ,"data":
+// The caller appends the data object and then closes it with '}'.
+func appendEnvelope(b []byte, typ string) []byte {
+	b = append(b, `{"version":`...)
+	b = strconv.AppendInt(b, wsProtocolVersion, 10)
+	b = append(b, `,"type":"`...)
+	b = append(b, typ...)
+	return append(b, `","data":`...)
+}
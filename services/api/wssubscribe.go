@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsReadLimit bounds a message from a /ws client; subscribe messages are a
+// few dozen bytes
+const wsReadLimit = 4096
+
+// wsRequest is a message from a /ws client:
+// {"subscribe":"btcusdt","history":100}
+type wsRequest struct {
+	Subscribe string `json:"subscribe"`
+	History   int    `json:"history"` // recent prices to send first
+}
+
+// handleWSMessage acts on one message from conn. A subscribe switches the
+// connection from the active symbol to the given one, after sending a
+// "history" batch of up to History of its recent prices (at most
+// RECENT_SIZE). Subscribing again replaces the subscription.
+func (s *Server) handleWSMessage(conn *websocket.Conn, data []byte) {
+	var req wsRequest
+	if err := json.Unmarshal(data, &req); err != nil || req.Subscribe == "" {
+		s.ws.Send(conn, wsError(statusErrorCode(http.StatusBadRequest), "Invalid message"))
+		return
+	}
+	if req.History < 0 {
+		s.ws.Send(conn, wsError(statusErrorCode(http.StatusBadRequest), "Invalid history"))
+		return
+	}
+	symbol := s.aliases.Normalize(req.Subscribe)
+	if getCoinName(symbol) == symbol {
+		s.ws.Send(conn, wsError(codeUnknownSymbol, "Unknown symbol"))
+		return
+	}
+
+	n := min(req.History, s.recent.size)
+	s.ws.Subscribe(conn, symbol, func() ([]byte, uint64) {
+		prices, seq := s.recent.lastSeq(symbol, n)
+		return wsHistory(symbol, prices), seq
+	})
+	metrics.Add("ws_subscribes", 1)
+}
+
+// wsHistory encodes a "history" message: {"symbol":...,"prices":[{"price":
+// ...,"time":...}, ...]}, oldest first
+func wsHistory(symbol string, prices []RecentPrice) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"symbol": symbol,
+		"prices": prices,
+	})
+	msg := appendEnvelope(make([]byte, 0, len(data)+48), wsTypeHistory)
+	msg = append(msg, data...)
+	return append(msg, '}')
+}
+
+// wsError encodes an "error" message with the same fields as the HTTP
+// error bodies
+func wsError(code, text string) []byte {
+	data, _ := json.Marshal(map[string]string{
+		"error": text,
+		"code":  code,
+	})
+	msg := appendEnvelope(make([]byte, 0, len(data)+48), wsTypeError)
+	msg = append(msg, data...)
+	return append(msg, '}')
+}
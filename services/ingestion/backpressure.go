@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// droppedTrades counts live trades discarded because the publish buffer
+// (TRADE_BUFFER) was full
+var droppedTrades atomic.Uint64
+
+// receivedTrades counts every trade a source produced, dropped or not
+var receivedTrades atomic.Uint64
+
+type streamTradesKey struct{}
+
+// withStreamTrades has offer and send also count the trades produced under
+// ctx in n, so each stream can tell whether it delivered anything itself
+func withStreamTrades(ctx context.Context, n *atomic.Uint64) context.Context {
+	return context.WithValue(ctx, streamTradesKey{}, n)
+}
+
+// countReceived counts a trade produced under ctx
+func countReceived(ctx context.Context) {
+	receivedTrades.Add(1)
+	if n, ok := ctx.Value(streamTradesKey{}).(*atomic.Uint64); ok {
+		n.Add(1)
+	}
+}
+
+// offer hands msg to the publisher without blocking. When the buffer is
+// full the trade is dropped and counted, so a slow publisher can't stall
+// the WebSocket reader into missing pings and getting disconnected.
+func offer(ctx context.Context, out chan<- TradeMessage, msg TradeMessage) {
+	countReceived(ctx)
+	select {
+	case out <- msg:
+	default:
+		if droppedTrades.Add(1) == 1 {
+			log.Println("Trade buffer full, dropping trades (raise TRADE_BUFFER if this persists)")
+		}
+	}
+}
+
+// reportDrops logs how many trades were dropped in each interval that had any
+func reportDrops(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n := droppedTrades.Load()
+			if n > last {
+				log.Printf("Dropped %d trades in the last %s (%d total)", n-last, interval, n)
+			}
+			last = n
+		}
+	}
+}
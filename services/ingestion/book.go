@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nats-io/nats.go"
+)
+
+// BookMessage is published to NATS on book.raw
+type BookMessage struct {
+	Symbol string  `json:"symbol"`
+	Bid    float64 `json:"bid"`
+	Ask    float64 `json:"ask"`
+	Time   int64   `json:"time"`
+}
+
+// BinanceBookTicker represents a bookTicker event from Binance. The
+// quantities ("B", "A") aren't used but need fields of their own:
+// encoding/json matches keys case-insensitively, so they'd overwrite the
+// prices.
+type BinanceBookTicker struct {
+	Bid    string `json:"b"`
+	BidQty string `json:"B"`
+	Ask    string `json:"a"`
+	AskQty string `json:"A"`
+}
+
+// parseBookTicker returns a bookTicker event's best bid and ask prices
+func parseBookTicker(message []byte) (bid, ask float64, ok bool) {
+	var ticker BinanceBookTicker
+	if err := json.Unmarshal(message, &ticker); err != nil {
+		return 0, 0, false
+	}
+	bid, err1 := strconv.ParseFloat(ticker.Bid, 64)
+	ask, err2 := strconv.ParseFloat(ticker.Ask, 64)
+	if err1 != nil || err2 != nil || bid <= 0 || ask <= 0 {
+		return 0, 0, false
+	}
+	return bid, ask, true
+}
+
+func connectToBookTicker(ctx context.Context, nc *nats.Conn, baseURL, symbol string) {
+	url := baseURL + "/ws/" + symbol + "@bookTicker"
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		log.Printf("Binance book connection error: %v", err)
+		return
+	}
+	defer conn.Close()
+	defer closeOnCancel(ctx, conn)()
+	armReadDeadline(conn)
+	log.Printf("Connected to Binance book ticker for %s", symbol)
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if isTimeout(err) {
+				log.Printf("No book data from Binance for %s in %s, reconnecting", symbol, readTimeout)
+			} else if ctx.Err() == nil {
+				log.Printf("Book read error: %v", err)
+			}
+			return
+		}
+		extendReadDeadline(conn)
+
+		message, streamSymbol := unwrapCombined(message)
+		bookSymbol := symbol
+		if streamSymbol != "" {
+			bookSymbol = streamSymbol
+		}
+
+		switch kind, detail := classifyFrame(message); kind {
+		case frameError:
+			log.Printf("Binance book stream error for %s, reconnecting: %s", symbol, detail)
+			return
+		case frameResult, frameInvalid:
+			continue
+		}
+
+		bid, ask, ok := parseBookTicker(message)
+		if !ok {
+			continue
+		}
+
+		// bookTicker events carry no timestamp, so stamp on receipt
+		data, _ := json.Marshal(BookMessage{
+			Symbol: bookSymbol,
+			Bid:    bid,
+			Ask:    ask,
+			Time:   time.Now().UnixMilli(),
+		})
+		nc.Publish("book.raw", data)
+	}
+}
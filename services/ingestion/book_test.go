@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestParseBookTicker(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    string
+		bid, ask float64
+		ok       bool
+	}{
+		{
+			// The quantities "B" and "A" follow the prices and differ from
+			// their keys only in case
+			name:  "spot",
+			event: `{"u":400900217,"s":"BNBUSDT","b":"25.35190000","B":"31.21000000","a":"25.36520000","A":"40.66000000"}`,
+			bid:   25.3519, ask: 25.3652, ok: true,
+		},
+		{
+			name:  "futures",
+			event: `{"e":"bookTicker","u":400900217,"E":1568014460893,"T":1568014460891,"s":"BNBUSDT","b":"25.35190000","B":"31.21000000","a":"25.36520000","A":"40.66000000"}`,
+			bid:   25.3519, ask: 25.3652, ok: true,
+		},
+		{
+			name:  "quantities first",
+			event: `{"B":"31.21","A":"40.66","b":"25.35","a":"25.36"}`,
+			bid:   25.35, ask: 25.36, ok: true,
+		},
+		{
+			name:  "empty side",
+			event: `{"b":"0","B":"0","a":"25.36","A":"1"}`,
+		},
+		{
+			name:  "not a ticker",
+			event: `{"result":null,"id":1}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bid, ask, ok := parseBookTicker([]byte(tt.event))
+			if ok != tt.ok || bid != tt.bid || ask != tt.ask {
+				t.Errorf("got %v %v %v, want %v %v %v", bid, ask, ok, tt.bid, tt.ask, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+// Command mockbinance serves a Binance-compatible @trade WebSocket stream
+// with synthetic random-walk prices, so the pipeline can run offline.
+//
+//	go run ./cmd/mockbinance -symbol btcusdt -price 65000 -tps 5
+//	BINANCE_WS_URL=ws://localhost:9443 go run .
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// binanceTrade mirrors the fields of Binance's trade event payload
+type binanceTrade struct {
+	EventType  string `json:"e"`
+	EventTime  int64  `json:"E"`
+	Symbol     string `json:"s"`
+	TradeID    int64  `json:"t"`
+	Price      string `json:"p"`
+	Quantity   string `json:"q"`
+	TradeTime  int64  `json:"T"`
+	BuyerMaker bool   `json:"m"`
+	Ignore     bool   `json:"M"`
+}
+
+func main() {
+	addr := flag.String("addr", ":9443", "listen address")
+	symbol := flag.String("symbol", "", "only serve this symbol (default: any symbol in the stream path)")
+	start := flag.Float64("price", 65000, "starting price")
+	volatility := flag.Float64("volatility", 0.0005, "per-tick standard deviation as a fraction of price")
+	tps := flag.Float64("tps", 5, "ticks per second")
+	flag.Parse()
+
+	if *tps <= 0 {
+		log.Fatal("-tps must be positive")
+	}
+	interval := time.Duration(float64(time.Second) / *tps)
+
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool { return true },
+	}
+
+	http.HandleFunc("/ws/", func(w http.ResponseWriter, r *http.Request) {
+		// Path is /ws/<symbol>@trade
+		stream := strings.TrimPrefix(r.URL.Path, "/ws/")
+		sym, _, _ := strings.Cut(stream, "@")
+		sym = strings.ToLower(sym)
+		if sym == "" || (*symbol != "" && sym != strings.ToLower(*symbol)) {
+			http.Error(w, "Unknown stream", http.StatusNotFound)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Printf("WebSocket upgrade error: %v", err)
+			return
+		}
+		defer conn.Close()
+		log.Printf("Client connected to %s", stream)
+
+		// Drain reads so pings/closes from the client are processed
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+		price := *start
+		var id int64
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-closed:
+				log.Printf("Client disconnected from %s", stream)
+				return
+			case now := <-ticker.C:
+				price *= 1 + *volatility*rng.NormFloat64()
+				if price <= 0 {
+					price = *start
+				}
+				id++
+
+				ms := now.UnixMilli()
+				data, _ := json.Marshal(binanceTrade{
+					EventType:  "trade",
+					EventTime:  ms,
+					Symbol:     strings.ToUpper(sym),
+					TradeID:    id,
+					Price:      strconv.FormatFloat(price, 'f', 8, 64),
+					Quantity:   strconv.FormatFloat(rng.Float64(), 'f', 8, 64),
+					TradeTime:  ms,
+					BuyerMaker: rng.Intn(2) == 0,
+					Ignore:     true,
+				})
+				if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+					return
+				}
+			}
+		}
+	})
+
+	log.Printf("Mock Binance stream on ws://localhost%s/ws/<symbol>@trade (%.1f ticks/s)", *addr, *tps)
+	if err := http.ListenAndServe(*addr, nil); err != nil {
+		log.Fatal(err)
+	}
+}
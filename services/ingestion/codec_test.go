@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestTradeMessageAppendJSON(t *testing.T) {
+	msgs := []TradeMessage{
+		{Symbol: "btcusdt", Price: 42000.1, Quantity: 0.5, Time: 1700000000120, TraceID: "abc123"},
+		{Symbol: "ethusdt", Price: 0.0000001, Time: 1},
+		{Symbol: "top3", Price: 1e21},
+		{Symbol: `we"ird<sym>`, Price: -0.5, Quantity: 3},
+		{Symbol: "btcusdt", Price: math.NaN()}, // no JSON form; see appendJSONFloat
+	}
+	for _, m := range msgs {
+		checkTradeJSON(t, m)
+	}
+
+	// Every float format encoding/json might pick
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		checkTradeJSON(t, TradeMessage{
+			Symbol:   "btcusdt",
+			Price:    math.Float64frombits(rng.Uint64()),
+			Quantity: rng.ExpFloat64() * math.Pow(10, float64(rng.Intn(40)-20)),
+			Time:     rng.Int63(),
+		})
+	}
+}
+
+func checkTradeJSON(t *testing.T, m TradeMessage) {
+	t.Helper()
+	got := string(m.AppendJSON(nil))
+	want, err := json.Marshal(m)
+	if err != nil {
+		// NaN and Inf: json.Marshal refuses, AppendJSON writes null
+		if !json.Valid([]byte(got)) {
+			t.Errorf("AppendJSON(%+v) = %s, not valid JSON", m, got)
+		}
+		return
+	}
+	if got != string(want) {
+		t.Errorf("AppendJSON = %s\njson.Marshal = %s", got, want)
+	}
+}
+
+var benchTrade = TradeMessage{Symbol: "btcusdt", Price: 42000.12, Quantity: 0.00123, Time: 1700000000120, TraceID: "Qn9ZYfIFxKqV0nIp4Qz3Jb"}
+
+func BenchmarkTradeMarshal(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(benchTrade); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// The pooled-buffer path publishMessage takes
+func BenchmarkTradeAppendJSON(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		bp := encodeBufPool.Get().(*[]byte)
+		*bp = benchTrade.AppendJSON((*bp)[:0])
+		encodeBufPool.Put(bp)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"shared/indexdef"
+)
+
+// depthLimits are the level counts Binance's depth endpoint accepts
+var depthLimits = []int{5, 10, 20, 50, 100, 500, 1000, 5000}
+
+func validDepthLevels(n int) bool {
+	for _, l := range depthLimits {
+		if n == l {
+			return true
+		}
+	}
+	return false
+}
+
+// DepthLevel is one price level of the order book
+type DepthLevel struct {
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity"`
+}
+
+// DepthMessage is published to NATS on book.depth: a REST snapshot of the
+// top of the book, best prices first
+type DepthMessage struct {
+	Symbol       string       `json:"symbol"`
+	Bids         []DepthLevel `json:"bids"`
+	Asks         []DepthLevel `json:"asks"`
+	LastUpdateID int64        `json:"last_update_id"`
+	Time         int64        `json:"time"`
+}
+
+// binanceDepth is the /api/v3/depth response; levels are [price, qty]
+// string pairs
+type binanceDepth struct {
+	LastUpdateID int64       `json:"lastUpdateId"`
+	Bids         [][2]string `json:"bids"`
+	Asks         [][2]string `json:"asks"`
+}
+
+var depthClient = &http.Client{Timeout: 10 * time.Second}
+
+// fetchDepth gets a depth snapshot for symbol from a Binance REST depth
+// endpoint (spot and futures take the same parameters)
+func fetchDepth(ctx context.Context, endpoint, symbol string, levels int) (DepthMessage, error) {
+	u := endpoint + "?symbol=" + url.QueryEscape(strings.ToUpper(symbol)) + "&limit=" + strconv.Itoa(levels)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return DepthMessage{}, err
+	}
+	resp, err := depthClient.Do(req)
+	if err != nil {
+		return DepthMessage{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return DepthMessage{}, fmt.Errorf("depth snapshot for %s: %s", symbol, resp.Status)
+	}
+
+	var raw binanceDepth
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return DepthMessage{}, fmt.Errorf("depth snapshot for %s: %w", symbol, err)
+	}
+	return DepthMessage{
+		Symbol:       symbol,
+		Bids:         parseDepthLevels(raw.Bids),
+		Asks:         parseDepthLevels(raw.Asks),
+		LastUpdateID: raw.LastUpdateID,
+		Time:         time.Now().UnixMilli(),
+	}, nil
+}
+
+func parseDepthLevels(raw [][2]string) []DepthLevel {
+	levels := make([]DepthLevel, 0, len(raw))
+	for _, l := range raw {
+		price, err1 := strconv.ParseFloat(l[0], 64)
+		qty, err2 := strconv.ParseFloat(l[1], 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		levels = append(levels, DepthLevel{Price: price, Quantity: qty})
+	}
+	return levels
+}
+
+// pollDepth publishes a depth snapshot for the current symbol every
+// interval until ctx is cancelled. Failures are logged and retried on the
+// next tick. Index symbols have no book and are skipped.
+func pollDepth(ctx context.Context, nc *nats.Conn, markets marketRouter, indexes indexdef.Set, symbols *symbolState, interval time.Duration, levels int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		sym, _ := symbols.get()
+		if _, isIndex := indexes[sym]; !isIndex {
+			depth, err := fetchDepth(ctx, markets.depthURL(sym), sym, levels)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Depth fetch error: %v", err)
+				}
+			} else {
+				data, _ := json.Marshal(depth)
+				nc.Publish("book.depth", data)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
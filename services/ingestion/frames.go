@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// frameKind classifies a raw frame received on a Binance stream
+type frameKind int
+
+const (
+	frameEvent   frameKind = iota // market data event (trade, bookTicker, ...)
+	frameResult                   // response to a SUBSCRIBE/LIST request
+	frameError                    // error payload; the stream should be reset
+	frameInvalid                  // not a JSON object
+)
+
+// binanceFrame holds the fields that tell market events apart from
+// control and error frames. "m" is a bool on trades but a message string
+// on {"e":"error"} frames, so it's kept raw. encoding/json matches keys
+// case-insensitively, so events' "E" (a number) and "M" need fields of
+// their own or they'd land in "e" and "m".
+type binanceFrame struct {
+	Event     string          `json:"e"`
+	EventTime json.RawMessage `json:"E"`
+	Result    json.RawMessage `json:"result"`
+	Error     json.RawMessage `json:"error"`
+	Code      json.RawMessage `json:"code"`
+	Msg       string          `json:"msg"`
+	M         json.RawMessage `json:"m"`
+	Ignore    json.RawMessage `json:"M"`
+}
+
+// classifyFrame inspects a frame and returns its kind plus a description
+// suitable for logging when it isn't a market event
+func classifyFrame(message []byte) (frameKind, string) {
+	var f binanceFrame
+	if err := json.Unmarshal(message, &f); err != nil {
+		return frameInvalid, err.Error()
+	}
+
+	switch {
+	case f.Event == "error":
+		var m string
+		json.Unmarshal(f.M, &m)
+		return frameError, m
+	case len(f.Error) > 0 && string(f.Error) != "null":
+		return frameError, string(f.Error)
+	case len(f.Code) > 0 && f.Msg != "":
+		return frameError, strings.TrimSpace(string(f.Code) + " " + f.Msg)
+	case f.Result != nil:
+		return frameResult, string(f.Result)
+	}
+	return frameEvent, f.Event
+}
+
+// combinedEnvelope wraps every event on a /stream?streams=... connection
+type combinedEnvelope struct {
+	Stream string          `json:"stream"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// unwrapCombined returns the inner payload and the symbol named by the
+// stream (e.g. "btcusdt@trade" -> "btcusdt") when message is a
+// combined-stream envelope. Bare single-stream frames are returned as-is
+// with an empty symbol.
+func unwrapCombined(message []byte) ([]byte, string) {
+	// Cheap check before paying for a second unmarshal on every frame
+	if !bytes.Contains(message, []byte(`"stream"`)) {
+		return message, ""
+	}
+
+	var env combinedEnvelope
+	if err := json.Unmarshal(message, &env); err != nil || env.Stream == "" || len(env.Data) == 0 {
+		return message, ""
+	}
+
+	symbol, _, _ := strings.Cut(env.Stream, "@")
+	return env.Data, strings.ToLower(symbol)
+}
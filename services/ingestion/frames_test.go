@@ -0,0 +1,124 @@
+package main
+
+import "testing"
+
+func TestClassifyFrame(t *testing.T) {
+	tests := []struct {
+		name   string
+		frame  string
+		kind   frameKind
+		detail string
+	}{
+		{
+			name:   "trade",
+			frame:  `{"e":"trade","E":1700000000123,"s":"BTCUSDT","t":1,"p":"42000.10","q":"0.5","T":1700000000120,"m":true,"M":true}`,
+			kind:   frameEvent,
+			detail: "trade",
+		},
+		{
+			name:   "aggTrade",
+			frame:  `{"e":"aggTrade","E":1700000000123,"s":"BTCUSDT","a":7,"p":"42000.10","q":"0.5","f":1,"l":2,"T":1700000000120,"m":false,"M":true}`,
+			kind:   frameEvent,
+			detail: "aggTrade",
+		},
+		{
+			name:   "kline",
+			frame:  `{"e":"kline","E":1700000000123,"s":"BTCUSDT","k":{"t":1700000000000,"i":"1m","c":"42000.10","x":false}}`,
+			kind:   frameEvent,
+			detail: "kline",
+		},
+		{
+			name:   "book ticker has no event name",
+			frame:  `{"u":400900217,"s":"BNBUSDT","b":"25.35","B":"31.21","a":"25.36","A":"40.66"}`,
+			kind:   frameEvent,
+			detail: "",
+		},
+		{
+			name:   "subscribe response",
+			frame:  `{"result":null,"id":1}`,
+			kind:   frameResult,
+			detail: "null",
+		},
+		{
+			name:   "list response",
+			frame:  `{"result":["btcusdt@trade"],"id":2}`,
+			kind:   frameResult,
+			detail: `["btcusdt@trade"]`,
+		},
+		{
+			name:   "error event",
+			frame:  `{"e":"error","m":"Max stream count exceeded"}`,
+			kind:   frameError,
+			detail: "Max stream count exceeded",
+		},
+		{
+			name:   "error object",
+			frame:  `{"error":{"code":2,"msg":"Invalid request"},"id":1}`,
+			kind:   frameError,
+			detail: `{"code":2,"msg":"Invalid request"}`,
+		},
+		{
+			name:   "code and msg",
+			frame:  `{"code":-1121,"msg":"Invalid symbol."}`,
+			kind:   frameError,
+			detail: "-1121 Invalid symbol.",
+		},
+		{
+			name:  "not JSON",
+			frame: `ping`,
+			kind:  frameInvalid,
+		},
+		{
+			name:  "truncated",
+			frame: `{"e":"trade","p":"4200`,
+			kind:  frameInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, detail := classifyFrame([]byte(tt.frame))
+			if kind != tt.kind {
+				t.Fatalf("kind = %d, want %d (detail %q)", kind, tt.kind, detail)
+			}
+			if tt.kind != frameInvalid && detail != tt.detail {
+				t.Errorf("detail = %q, want %q", detail, tt.detail)
+			}
+		})
+	}
+}
+
+// A stream delivers control, error and market frames in any order; each
+// must be told apart on its own
+func TestClassifyFrameMixedStream(t *testing.T) {
+	stream := []struct {
+		frame string
+		kind  frameKind
+	}{
+		{`{"result":null,"id":1}`, frameResult},
+		{`{"e":"trade","E":1,"p":"1.5","q":"2","T":1,"m":false,"M":true}`, frameEvent},
+		{`{"e":"trade","E":2,"p":"1.6","q":"1","T":2,"m":true,"M":true}`, frameEvent},
+		{`not json`, frameInvalid},
+		{`{"e":"trade","E":3,"p":"1.7","q":"1","T":3,"m":false,"M":true}`, frameEvent},
+		{`{"e":"error","m":"Stream reset"}`, frameError},
+	}
+
+	for i, f := range stream {
+		if kind, detail := classifyFrame([]byte(f.frame)); kind != f.kind {
+			t.Errorf("frame %d %s: kind = %d (%q), want %d", i, f.frame, kind, detail, f.kind)
+		}
+	}
+}
+
+func TestUnwrapCombined(t *testing.T) {
+	inner := `{"e":"trade","E":1,"p":"1.5"}`
+	data, symbol := unwrapCombined([]byte(`{"stream":"BTCUSDT@trade","data":` + inner + `}`))
+	if string(data) != inner || symbol != "btcusdt" {
+		t.Errorf("got %s, %q", data, symbol)
+	}
+
+	bare := []byte(inner)
+	if data, symbol := unwrapCombined(bare); string(data) != inner || symbol != "" {
+		t.Errorf("bare frame: got %s, %q", data, symbol)
+	}
+}
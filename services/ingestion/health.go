@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/nats-io/nats.go"
+
+	"shared/buildinfo"
+)
+
+// startHealthServer serves /healthz and /version on addr (HEALTH_ADDR) for
+// container probes and deploy checks. It uses its own mux so nothing else
+// leaks onto the port.
+func startHealthServer(addr string, nc *nats.Conn) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		status, code := "ok", http.StatusOK
+		if !nc.IsConnected() {
+			status, code = "nats disconnected", http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":         status,
+			"dropped_trades": droppedTrades.Load(),
+		})
+	})
+	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(buildinfo.Info(version, commit, buildTime))
+	})
+
+	go func() {
+		log.Printf("Health server listening on %s", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("Health server error: %v", err)
+		}
+	}()
+}
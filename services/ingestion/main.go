@@ -1,27 +1,47 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
+	"net"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"shared/indexdef"
+	"shared/msgcodec"
+	"shared/natsconn"
+	"shared/symbolalias"
+	"shared/tracing"
 )
 
 // TradeMessage is published to NATS
 type TradeMessage struct {
-	Symbol string  `json:"symbol"`
-	Price  float64 `json:"price"`
-	Time   int64   `json:"time"`
+	Symbol   string  `json:"symbol"`
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity,omitempty"` // base asset traded; 0 when the stream doesn't say
+	Time     int64   `json:"time"`
+	TraceID  string  `json:"trace_id,omitempty"` // follows the trade through every hop
 }
 
-// BinanceTrade represents a trade event from Binance
+// BinanceTrade represents a trade or aggTrade event from Binance
 type BinanceTrade struct {
-	Price string `json:"p"`
-	Time  int64  `json:"T"`
+	Price    string `json:"p"`
+	Quantity string `json:"q"`
+	Time     int64  `json:"T"`
 }
 
 func main() {
@@ -35,13 +55,138 @@ func main() {
 		natsURL = "nats://localhost:4222"
 	}
 
-	log.Printf("Ingestion service starting for %s", symbol)
+	// Base stream URL, overridable for the testnet or a local mock
+	binanceURL := os.Getenv("BINANCE_WS_URL")
+	if binanceURL == "" {
+		binanceURL = "wss://stream.binance.com:9443"
+	}
+	binanceURL = strings.TrimRight(binanceURL, "/")
+
+	if v := os.Getenv("BINANCE_READ_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			log.Fatalf("Invalid BINANCE_READ_TIMEOUT %q (e.g. 30s, 0 disables)", v)
+		}
+		readTimeout = d
+	}
+
+	if v := os.Getenv("STREAM_TYPE"); v != "" {
+		if !validStreamType(v) {
+			log.Fatalf("Invalid STREAM_TYPE %q (trade, aggTrade or kline_<interval>)", v)
+		}
+		streamType = v
+	}
+
+	// USDT-margined futures symbols, marked "market": "futures" in the
+	// API's COINS_FILE, stream from separate endpoints
+	futuresWS := os.Getenv("BINANCE_FUTURES_WS_URL")
+	if futuresWS == "" {
+		futuresWS = "wss://fstream.binance.com"
+	}
+	futuresREST := os.Getenv("BINANCE_FUTURES_REST_URL")
+	if futuresREST == "" {
+		futuresREST = "https://fapi.binance.com"
+	}
+	restURL := os.Getenv("BINANCE_REST_URL")
+	if restURL == "" {
+		restURL = "https://api.binance.com"
+	}
+	futures, err := loadFuturesSymbols(os.Getenv("COINS_FILE"))
+	if err != nil {
+		log.Fatalf("Failed to load markets from COINS_FILE: %v", err)
+	}
+	markets := marketRouter{
+		spotWS:      binanceURL,
+		futuresWS:   strings.TrimRight(futuresWS, "/"),
+		spotREST:    strings.TrimRight(restURL, "/"),
+		futuresREST: strings.TrimRight(futuresREST, "/"),
+		futures:     futures,
+	}
+	if len(futures) > 0 {
+		log.Printf("%d futures symbols routed to %s", len(futures), markets.futuresWS)
+	}
+
+	source, err := newPriceSource(os.Getenv("SOURCE"), markets)
+	if err != nil {
+		log.Fatalf("Invalid source: %v", err)
+	}
+
+	// Other spellings of symbols (SYMBOL_ALIASES), shared with the API
+	aliases, err := symbolalias.Parse(os.Getenv("SYMBOL_ALIASES"))
+	if err != nil {
+		log.Fatalf("Invalid SYMBOL_ALIASES: %v", err)
+	}
+	symbol = aliases.Normalize(symbol)
+
+	// Index symbols (INDEXES) stream all their constituents at once
+	indexes, err := indexdef.Parse(os.Getenv("INDEXES"))
+	if err != nil {
+		log.Fatalf("Invalid INDEXES: %v", err)
+	}
+
+	// Trades buffered between the source and the NATS publisher
+	tradeBuffer := 100
+	if v := os.Getenv("TRADE_BUFFER"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Fatalf("Invalid TRADE_BUFFER %q (must be >= 1)", v)
+		}
+		tradeBuffer = n
+	}
+
+	if wireCodec, err = msgcodec.Parse(os.Getenv("MSG_FORMAT")); err != nil {
+		log.Fatalf("Invalid MSG_FORMAT: %v", err)
+	}
+
+	// Stop a symbol's stream after this many failed connections in a row
+	// (0 retries forever), so a bad symbol doesn't retry for good
+	maxFailures := 0
+	if v := os.Getenv("MAX_CONSECUTIVE_FAILURES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatalf("Invalid MAX_CONSECUTIVE_FAILURES %q (0 for unlimited)", v)
+		}
+		maxFailures = n
+	}
+
+	// Periodic REST depth snapshots (TRACK_DEPTH), kept coarse to limit
+	// request weight against Binance
+	trackDepth := os.Getenv("TRACK_DEPTH") == "true"
+	depthInterval := 10 * time.Second
+	if v := os.Getenv("DEPTH_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Second {
+			log.Fatalf("Invalid DEPTH_INTERVAL %q (at least 1s)", v)
+		}
+		depthInterval = d
+	}
+	depthLevels := 20
+	if v := os.Getenv("DEPTH_LEVELS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || !validDepthLevels(n) {
+			log.Fatalf("Invalid DEPTH_LEVELS %q (one of %v)", v, depthLimits)
+		}
+		depthLevels = n
+	}
+
+	sourceName := os.Getenv("SOURCE")
+	if sourceName == "" {
+		sourceName = "binance"
+	}
+	log.Printf("Ingestion service %s (%s) starting for %s (source: %s, stream: %s@%s)", version, commit, symbol, sourceName, streamType, binanceURL)
+
+	// Cancel everything on SIGINT/SIGTERM so the stream loop exits cleanly
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing := tracing.Init(ctx, "ingestion")
+	defer shutdownTracing(context.Background())
 
 	// Connect to NATS with retry
+	natsOpts := natsconn.Options()
 	var nc *nats.Conn
-	var err error
 	for i := 0; i < 10; i++ {
-		nc, err = nats.Connect(natsURL)
+		nc, err = nats.Connect(natsURL, natsOpts...)
 		if err == nil {
 			break
 		}
@@ -54,9 +199,43 @@ func main() {
 	defer nc.Close()
 	log.Println("Connected to NATS")
 
+	if addr := os.Getenv("HEALTH_ADDR"); addr != "" {
+		startHealthServer(addr, nc)
+	}
+
 	// Track current symbol for dynamic switching
-	var mu sync.RWMutex
-	currentSymbol := symbol
+	symbols := newSymbolState(symbol)
+
+	// Trades stream for the current symbol plus any tracked set, one
+	// connection per symbol. A stream that delivered any trade of its own
+	// counts as a successful connection and resets its failure count, even
+	// if it later dropped. One that keeps failing stops on its own; the
+	// others, and ingestion, carry on.
+	trades := make(chan TradeMessage, tradeBuffer)
+	var streams *trackedStreams
+	streams = newTrackedStreams(symbol, func() func(context.Context, string) {
+		failures := 0
+		var delivered atomic.Uint64
+		return func(streamCtx context.Context, sym string) {
+			before := delivered.Load()
+			err := source.Stream(withStreamTrades(streamCtx, &delivered), indexes.SymbolsFor(sym), trades)
+			if delivered.Load() > before {
+				failures = 0
+			}
+			if err == nil {
+				return
+			}
+			log.Printf("Price source error: %v", err)
+			if delivered.Load() == before {
+				failures++
+				if maxFailures > 0 && failures >= maxFailures {
+					log.Printf("Giving up on %s after %d consecutive %s failures", sym, failures, sourceName)
+					streams.giveUp(sym)
+					<-streamCtx.Done()
+				}
+			}
+		}
+	})
 
 	// Subscribe to symbol change requests
 	nc.Subscribe("control.symbol", func(msg *nats.Msg) {
@@ -66,68 +245,226 @@ func main() {
 		if err := json.Unmarshal(msg.Data, &req); err != nil {
 			return
 		}
-		mu.Lock()
-		currentSymbol = req.Symbol
-		mu.Unlock()
-		log.Printf("Symbol changed to %s", req.Symbol)
+		sym := aliases.Normalize(req.Symbol)
+		if sym == "" {
+			return
+		}
+		symbols.set(sym)
+		streams.setActive(sym)
+		log.Printf("Symbol changed to %s", sym)
 	})
 
-	// Start Binance connection loop
-	for {
-		mu.RLock()
-		sym := currentSymbol
-		mu.RUnlock()
+	// Replace the whole tracked set at once; only streams for symbols added
+	// or removed reconnect
+	nc.Subscribe("control.symbols", func(msg *nats.Msg) {
+		var req struct {
+			Symbols []string `json:"symbols"`
+		}
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			return
+		}
+		set := make([]string, 0, len(req.Symbols))
+		for _, s := range req.Symbols {
+			if sym := aliases.Normalize(s); sym != "" {
+				set = append(set, sym)
+			}
+		}
+		started, stopped := streams.setTracked(set)
+		log.Printf("Tracked symbols set to [%s] (%d streams started, %d stopped)", strings.Join(set, ","), started, stopped)
+	})
 
-		connectToBinance(nc, sym, &mu, &currentSymbol)
-		time.Sleep(2 * time.Second)
+	// Optionally track best bid/ask alongside trades
+	if os.Getenv("TRACK_BOOK") == "true" {
+		log.Println("Book ticker tracking enabled")
+		go runStreamLoop(ctx, symbols, func(streamCtx context.Context, sym string) {
+			if _, ok := indexes[sym]; ok {
+				<-streamCtx.Done() // an index has no book of its own
+				return
+			}
+			connectToBookTicker(streamCtx, nc, markets.wsURL(sym), sym)
+		})
 	}
+
+	// Optionally publish order book depth snapshots on book.depth
+	if trackDepth {
+		log.Printf("Depth snapshots enabled (%d levels every %s)", depthLevels, depthInterval)
+		go pollDepth(ctx, nc, markets, indexes, symbols, depthInterval, depthLevels)
+	}
+
+	// Announce the source and symbol for the API's /api/pipeline/status.
+	// Core NATS doesn't retain messages, so repeat it for late subscribers.
+	go func() {
+		for {
+			sym, _ := symbols.get()
+			status, _ := json.Marshal(map[string]interface{}{
+				"source":         sourceName,
+				"symbol":         sym,
+				"symbols":        streams.symbols(),
+				"failed_symbols": streams.failedSymbols(),
+				"rest_fallback":  restPolling.Load(),
+				"stream":         streamType,
+				"version":        version,
+				"dropped_trades": droppedTrades.Load(),
+			})
+			nc.Publish("status.ingestion", status)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(30 * time.Second):
+			}
+		}
+	}()
+
+	// Sources only produce trades; tracing and publishing happen here
+	go publishTrades(ctx, nc, trades)
+	go reportDrops(ctx, time.Minute)
+
+	streams.run(ctx)
+	log.Println("Ingestion service shutting down")
 }
 
-func connectToBinance(nc *nats.Conn, symbol string, mu *sync.RWMutex, currentSymbol *string) {
-	url := "wss://stream.binance.com:9443/ws/" + symbol + "@trade"
+// symbolState is the symbol being streamed. changed is closed and replaced
+// on every switch so running streams know to stop.
+type symbolState struct {
+	mu      sync.RWMutex
+	symbol  string
+	changed chan struct{}
+}
 
-	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
-	if err != nil {
-		log.Printf("Binance connection error: %v", err)
-		return
+func newSymbolState(symbol string) *symbolState {
+	return &symbolState{symbol: symbol, changed: make(chan struct{})}
+}
+
+func (s *symbolState) get() (string, <-chan struct{}) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.symbol, s.changed
+}
+
+func (s *symbolState) set(symbol string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.symbol = symbol
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// runStreamLoop reconnects a stream for the current symbol until ctx is
+// cancelled. The context passed to connect is also cancelled when the
+// symbol changes, and the new symbol is connected without the usual delay.
+func runStreamLoop(ctx context.Context, symbols *symbolState, connect func(ctx context.Context, symbol string)) {
+	for ctx.Err() == nil {
+		sym, changed := symbols.get()
+
+		streamCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-changed:
+				log.Printf("Symbol changed, reconnecting...")
+				cancel()
+			case <-streamCtx.Done():
+			}
+		}()
+		connect(streamCtx, sym)
+		cancel()
+
+		select {
+		case <-ctx.Done():
+		case <-changed:
+		case <-time.After(2 * time.Second):
+		}
 	}
-	defer conn.Close()
-	log.Printf("Connected to Binance for %s", symbol)
+}
 
+// publishTrades stamps each trade with a trace ID and publishes it on
+// trades.raw until ctx is cancelled
+func publishTrades(ctx context.Context, nc *nats.Conn, trades <-chan TradeMessage) {
 	for {
-		// Check if symbol changed
-		mu.RLock()
-		newSymbol := *currentSymbol
-		mu.RUnlock()
-		if newSymbol != symbol {
-			log.Printf("Symbol changed, reconnecting...")
+		var msg TradeMessage
+		select {
+		case <-ctx.Done():
 			return
+		case msg = <-trades:
 		}
 
-		_, message, err := conn.ReadMessage()
-		if err != nil {
-			log.Printf("Read error: %v", err)
-			return
+		if msg.TraceID == "" {
+			msg.TraceID = nuid.Next()
 		}
+		logTrace("ingest", msg.TraceID, msg.Symbol, msg.Price)
+		spanCtx, span := tracing.Tracer.Start(ctx, "ingest.publish",
+			trace.WithSpanKind(trace.SpanKindProducer),
+			trace.WithAttributes(
+				attribute.String("symbol", msg.Symbol),
+				attribute.String("trace_id", msg.TraceID),
+			))
+		publishMessage(spanCtx, nc, "trades.raw", msg)
+		span.End()
+	}
+}
 
-		var trade BinanceTrade
-		if err := json.Unmarshal(message, &trade); err != nil {
-			continue
-		}
+// readTimeout is how long a stream may go quiet before it's treated as dead
+var readTimeout = 30 * time.Second
 
-		var price float64
-		if _, err := json.Number(trade.Price).Float64(); err == nil {
-			json.Unmarshal([]byte(trade.Price), &price)
+// armReadDeadline makes ReadMessage fail once the stream has been silent for
+// readTimeout, so a half-open TCP connection ends in a reconnect instead of
+// a silent stall. Pings from Binance count as activity.
+func armReadDeadline(conn *websocket.Conn) {
+	if readTimeout <= 0 {
+		return
+	}
+	conn.SetReadDeadline(time.Now().Add(readTimeout))
+	conn.SetPingHandler(func(data string) error {
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		if err == websocket.ErrCloseSent {
+			return nil
 		}
+		return err
+	})
+}
 
-		if price > 0 {
-			msg := TradeMessage{
-				Symbol: symbol,
-				Price:  price,
-				Time:   trade.Time,
-			}
-			data, _ := json.Marshal(msg)
-			nc.Publish("trades.raw", data)
+// extendReadDeadline pushes the deadline out after a successful read
+func extendReadDeadline(conn *websocket.Conn) {
+	if readTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
+	}
+}
+
+// isTimeout reports whether err came from the read deadline expiring
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// closeOnCancel closes conn when ctx is cancelled, since ReadMessage doesn't
+// take a context. Call the returned func once the connection is done.
+func closeOnCancel(ctx context.Context, conn *websocket.Conn) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
 		}
+	}()
+	return func() { close(done) }
+}
+
+// AppendJSON encodes the trade without reflection; it matches json.Marshal
+func (m TradeMessage) AppendJSON(b []byte) []byte {
+	b = append(b, `{"symbol":`...)
+	b = appendJSONString(b, m.Symbol)
+	b = append(b, `,"price":`...)
+	b = appendJSONFloat(b, m.Price)
+	if m.Quantity != 0 {
+		b = append(b, `,"quantity":`...)
+		b = appendJSONFloat(b, m.Quantity)
+	}
+	b = append(b, `,"time":`...)
+	b = strconv.AppendInt(b, m.Time, 10)
+	if m.TraceID != "" {
+		b = append(b, `,"trace_id":`...)
+		b = appendJSONString(b, m.TraceID)
 	}
+	return append(b, '}')
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Markets a coin can trade on, the "market" field of COINS_FILE entries
+const (
+	marketSpot    = "spot"
+	marketFutures = "futures" // USDT-margined futures
+)
+
+// marketRouter picks Binance endpoints per symbol: spot by default,
+// futures for symbols COINS_FILE marks "market": "futures"
+type marketRouter struct {
+	spotWS, futuresWS     string
+	spotREST, futuresREST string
+	futures               map[string]bool
+}
+
+// loadFuturesSymbols reads the symbols marked as futures from the API's
+// coin file; other coin fields are the API's business
+func loadFuturesSymbols(path string) (map[string]bool, error) {
+	futures := make(map[string]bool)
+	if path == "" {
+		return futures, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var coins []struct {
+		Symbol string `json:"symbol"`
+		Market string `json:"market"`
+	}
+	if err := json.Unmarshal(data, &coins); err != nil {
+		return nil, err
+	}
+	for _, c := range coins {
+		switch c.Market {
+		case "", marketSpot:
+		case marketFutures:
+			futures[strings.ToLower(c.Symbol)] = true
+		default:
+			return nil, fmt.Errorf("coin %s: unknown market %q (spot or futures)", c.Symbol, c.Market)
+		}
+	}
+	return futures, nil
+}
+
+func (m marketRouter) isFutures(symbol string) bool {
+	return m.futures[symbol]
+}
+
+// wsURL is the stream base URL for symbol's market
+func (m marketRouter) wsURL(symbol string) string {
+	if m.isFutures(symbol) {
+		return m.futuresWS
+	}
+	return m.spotWS
+}
+
+// depthURL is the REST depth snapshot endpoint for symbol's market
+func (m marketRouter) depthURL(symbol string) string {
+	if m.isFutures(symbol) {
+		return m.futuresREST + "/fapi/v1/depth"
+	}
+	return m.spotREST + "/api/v3/depth"
+}
+
+// tradesURL is the REST recent-trades endpoint for symbol's market, polled
+// when WebSockets are blocked (REST_FALLBACK_AFTER)
+func (m marketRouter) tradesURL(symbol string) string {
+	if m.isFutures(symbol) {
+		return m.futuresREST + "/fapi/v1/trades"
+	}
+	return m.spotREST + "/api/v3/trades"
+}
+
+// streamFor is the stream type to request for symbol. Futures have no raw
+// trade stream, so "trade" becomes "aggTrade" there; both parse the same.
+func (m marketRouter) streamFor(symbol string) string {
+	if m.isFutures(symbol) && streamType == "trade" {
+		return "aggTrade"
+	}
+	return streamType
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// mockSource generates random-walk trades in-process, like cmd/mockbinance
+// without the WebSocket hop
+type mockSource struct {
+	interval   time.Duration
+	start      float64
+	volatility float64 // per-tick standard deviation as a fraction of price
+}
+
+func (s mockSource) Stream(ctx context.Context, symbols []string, out chan<- TradeMessage) error {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	prices := make([]float64, len(symbols))
+	for i := range prices {
+		prices[i] = s.start
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			for i, sym := range symbols {
+				prices[i] *= 1 + s.volatility*rng.NormFloat64()
+				if prices[i] <= 0 {
+					prices[i] = s.start
+				}
+				offer(ctx, out, TradeMessage{Symbol: sym, Price: prices[i], Quantity: rng.Float64(), Time: now.UnixMilli()})
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxReplayGap caps the pause between replayed trades, so a log spanning
+// restarts or quiet hours doesn't stall the replay
+const maxReplayGap = 5 * time.Second
+
+// replaySource plays back a JSON-lines trade file, such as the API's
+// TRADE_LOG_FILE, keeping the original spacing between trades divided by
+// speed (0 plays as fast as the publisher takes them). Trades are stamped
+// with the time they're replayed so downstream windows see them as live.
+type replaySource struct {
+	path  string
+	speed float64
+}
+
+func (s replaySource) Stream(ctx context.Context, symbols []string, out chan<- TradeMessage) error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("replay: %w", err)
+	}
+	defer f.Close()
+
+	wanted := func(sym string) bool {
+		for _, s := range symbols {
+			if strings.EqualFold(s, sym) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var prev int64
+	sent := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var trade TradeMessage
+		if err := json.Unmarshal(scanner.Bytes(), &trade); err != nil || trade.Price <= 0 || !wanted(trade.Symbol) {
+			continue
+		}
+
+		if s.speed > 0 && prev != 0 && trade.Time > prev {
+			gap := min(time.Duration(float64(time.Duration(trade.Time-prev)*time.Millisecond)/s.speed), maxReplayGap)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(gap):
+			}
+		}
+		prev = trade.Time
+
+		trade.Symbol = strings.ToLower(trade.Symbol)
+		trade.Time = time.Now().UnixMilli()
+		trade.TraceID = ""
+		if !send(ctx, out, trade) {
+			return nil
+		}
+		sent++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("replay: %w", err)
+	}
+	log.Printf("Replayed %d trades from %s", sent, s.path)
+	return nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// restTradesLimit is how many recent trades each poll asks for; trades
+// beyond that between two polls are missed
+const restTradesLimit = 100
+
+// wsProbeInterval is how often polling checks whether the WebSocket can be
+// opened again
+const wsProbeInterval = time.Minute
+
+// restPolling is set while trades come from REST polling instead of the
+// WebSocket, for status.ingestion
+var restPolling atomic.Bool
+
+var restClient = &http.Client{Timeout: 10 * time.Second}
+
+// restFallback streams from the Binance WebSocket, and after `after`
+// connection attempts in a row fail to open it (e.g. a network that
+// blocks WebSockets but allows HTTPS) polls the REST recent-trades
+// endpoint instead. Polling publishes the trades since the previous poll,
+// so bursts of more than restTradesLimit trades per interval lose some.
+// Every wsProbeInterval it tries the WebSocket again and switches back
+// once it opens. The state is shared by every stream, since a blocked
+// WebSocket is a property of the network.
+type restFallback struct {
+	ws       binanceSource
+	after    int
+	interval time.Duration
+
+	mu       sync.Mutex
+	failures int // connection attempts in a row that failed to open
+}
+
+func newRESTFallback(ws binanceSource, after int, interval time.Duration) *restFallback {
+	return &restFallback{ws: ws, after: after, interval: interval}
+}
+
+func (f *restFallback) Stream(ctx context.Context, symbols []string, out chan<- TradeMessage) error {
+	if !restPolling.Load() {
+		err := f.ws.Stream(ctx, symbols, out)
+		if !errors.Is(err, errConnect) {
+			f.mu.Lock()
+			f.failures = 0
+			f.mu.Unlock()
+			return err
+		}
+
+		f.mu.Lock()
+		f.failures++
+		blocked := f.failures >= f.after
+		f.mu.Unlock()
+		if !blocked {
+			return err
+		}
+		if !restPolling.Swap(true) {
+			log.Printf("%v; WebSocket failed %d times in a row, polling REST every %s", err, f.after, f.interval)
+		}
+	}
+	return f.poll(ctx, symbols, out)
+}
+
+// poll publishes new trades for symbols every interval until ctx is
+// cancelled or the WebSocket opens again
+func (f *restFallback) poll(ctx context.Context, symbols []string, out chan<- TradeMessage) error {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	probe := time.NewTicker(wsProbeInterval)
+	defer probe.Stop()
+
+	lastID := make(map[string]int64, len(symbols))
+	for {
+		for _, sym := range symbols {
+			if err := f.pollSymbol(ctx, sym, lastID, out); err != nil && ctx.Err() == nil {
+				log.Printf("REST poll error: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-probe.C:
+			if f.probe(ctx, symbols) {
+				return nil
+			}
+		case <-ticker.C:
+		}
+		// Another stream's probe may have found the WebSocket back
+		if !restPolling.Load() {
+			return nil
+		}
+	}
+}
+
+// pollSymbol publishes symbol's trades newer than lastID[symbol]. The first
+// poll only publishes the latest trade, so older ones aren't replayed out
+// of order.
+func (f *restFallback) pollSymbol(ctx context.Context, symbol string, lastID map[string]int64, out chan<- TradeMessage) error {
+	trades, err := fetchTrades(ctx, f.ws.markets.tradesURL(symbol), symbol, restTradesLimit)
+	if err != nil || len(trades) == 0 {
+		return err
+	}
+	last, seen := lastID[symbol]
+	if !seen {
+		trades = trades[len(trades)-1:]
+	}
+	for _, t := range trades {
+		if seen && t.ID <= last {
+			continue
+		}
+		price, err := strconv.ParseFloat(t.Price, 64)
+		if err != nil || price <= 0 {
+			continue
+		}
+		qty, _ := strconv.ParseFloat(t.Quantity, 64)
+		offer(ctx, out, TradeMessage{Symbol: symbol, Price: price, Quantity: max(qty, 0), Time: t.Time})
+	}
+	lastID[symbol] = trades[len(trades)-1].ID
+	return nil
+}
+
+// probe reports whether the WebSocket for symbols opens again, and if so
+// ends polling
+func (f *restFallback) probe(ctx context.Context, symbols []string) bool {
+	u, _, err := f.ws.streamURL(symbols)
+	if err != nil {
+		return false
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	conn, _, err := websocket.DefaultDialer.DialContext(dialCtx, u, nil)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+
+	f.mu.Lock()
+	f.failures = 0
+	f.mu.Unlock()
+	if restPolling.Swap(false) {
+		log.Println("WebSocket reachable again, stopping REST polling")
+	}
+	return true
+}
+
+// binanceRESTTrade is one entry of the recent-trades response; spot and
+// futures use the same fields
+type binanceRESTTrade struct {
+	ID       int64  `json:"id"`
+	Price    string `json:"price"`
+	Quantity string `json:"qty"`
+	Time     int64  `json:"time"`
+}
+
+// fetchTrades gets symbol's most recent trades, oldest first
+func fetchTrades(ctx context.Context, endpoint, symbol string, limit int) ([]binanceRESTTrade, error) {
+	u := endpoint + "?symbol=" + url.QueryEscape(strings.ToUpper(symbol)) + "&limit=" + strconv.Itoa(limit)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := restClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("recent trades for %s: %s", symbol, resp.Status)
+	}
+
+	var trades []binanceRESTTrade
+	if err := json.NewDecoder(resp.Body).Decode(&trades); err != nil {
+		return nil, fmt.Errorf("recent trades for %s: %w", symbol, err)
+	}
+	return trades, nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// PriceSource produces trades for symbols on out until ctx is cancelled.
+// It returns nil when it stops cleanly (ctx cancelled or a finite feed ran
+// out) and an error when the feed failed; either way the caller reconnects.
+type PriceSource interface {
+	Stream(ctx context.Context, symbols []string, out chan<- TradeMessage) error
+}
+
+// newPriceSource builds the source named by SOURCE: "binance" (default),
+// "mock" or "replay"
+func newPriceSource(kind string, markets marketRouter) (PriceSource, error) {
+	switch kind {
+	case "", "binance":
+		ws := binanceSource{markets: markets}
+		v := os.Getenv("REST_FALLBACK_AFTER")
+		if v == "" {
+			return ws, nil
+		}
+		after, err := strconv.Atoi(v)
+		if err != nil || after < 0 {
+			return nil, fmt.Errorf("invalid REST_FALLBACK_AFTER %q (failed connections before polling REST, 0 disables)", v)
+		}
+		if after == 0 {
+			return ws, nil
+		}
+		interval := 2 * time.Second
+		if v := os.Getenv("REST_POLL_INTERVAL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 500*time.Millisecond {
+				return nil, fmt.Errorf("invalid REST_POLL_INTERVAL %q (at least 500ms)", v)
+			}
+			interval = d
+		}
+		return newRESTFallback(ws, after, interval), nil
+	case "mock":
+		tps := 5.0
+		if v := os.Getenv("MOCK_TPS"); v != "" {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid MOCK_TPS %q (ticks per second, > 0)", v)
+			}
+			tps = n
+		}
+		return mockSource{
+			interval:   time.Duration(float64(time.Second) / tps),
+			start:      65000,
+			volatility: 0.0005,
+		}, nil
+	case "replay":
+		path := os.Getenv("REPLAY_FILE")
+		if path == "" {
+			return nil, fmt.Errorf("SOURCE=replay needs REPLAY_FILE")
+		}
+		speed := 1.0
+		if v := os.Getenv("REPLAY_SPEED"); v != "" {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid REPLAY_SPEED %q (0 replays as fast as possible)", v)
+			}
+			speed = n
+		}
+		return replaySource{path: path, speed: speed}, nil
+	}
+	return nil, fmt.Errorf("unknown SOURCE %q (binance, mock or replay)", kind)
+}
+
+// send hands msg to the publisher, waiting for room in the buffer and giving
+// up if ctx ends first. Only sources that can pause, like replay, use it;
+// live feeds go through offer instead.
+func send(ctx context.Context, out chan<- TradeMessage, msg TradeMessage) bool {
+	countReceived(ctx)
+	select {
+	case out <- msg:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// errConnect marks a failure to open the Binance WebSocket at all, as
+// opposed to one that dropped after connecting
+var errConnect = errors.New("binance connection error")
+
+// binanceSource streams STREAM_TYPE events from Binance; several symbols
+// share one combined-stream connection, as long as they're on the same
+// market (spot or futures)
+type binanceSource struct {
+	markets marketRouter
+}
+
+// streamURL is the WebSocket URL streaming symbols, and whether they're
+// futures
+func (s binanceSource) streamURL(symbols []string) (url string, futures bool, err error) {
+	futures = s.markets.isFutures(symbols[0])
+	for _, sym := range symbols[1:] {
+		if s.markets.isFutures(sym) != futures {
+			return "", false, fmt.Errorf("can't combine spot and futures symbols in one stream (%s)", strings.Join(symbols, ","))
+		}
+	}
+	baseURL := s.markets.wsURL(symbols[0])
+	stream := s.markets.streamFor(symbols[0])
+
+	url = baseURL + "/ws/" + symbols[0] + "@" + stream
+	if len(symbols) > 1 {
+		streams := make([]string, len(symbols))
+		for i, sym := range symbols {
+			streams[i] = sym + "@" + stream
+		}
+		url = baseURL + "/stream?streams=" + strings.Join(streams, "/")
+	}
+	return url, futures, nil
+}
+
+func (s binanceSource) Stream(ctx context.Context, symbols []string, out chan<- TradeMessage) error {
+	url, futures, err := s.streamURL(symbols)
+	if err != nil {
+		return err
+	}
+	stream := s.markets.streamFor(symbols[0])
+	name := strings.Join(symbols, ",")
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errConnect, err)
+	}
+	defer conn.Close()
+	if futures {
+		log.Printf("Connected to Binance futures for %s", name)
+	} else {
+		log.Printf("Connected to Binance for %s", name)
+	}
+
+	defer closeOnCancel(ctx, conn)()
+	armReadDeadline(conn)
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if isTimeout(err) {
+				return fmt.Errorf("no data from Binance for %s in %s", name, readTimeout)
+			}
+			return fmt.Errorf("read error: %w", err)
+		}
+		extendReadDeadline(conn)
+
+		// Combined streams wrap the event and name its symbol in the envelope
+		message, streamSymbol := unwrapCombined(message)
+		tradeSymbol := symbols[0]
+		if streamSymbol != "" {
+			tradeSymbol = streamSymbol
+		}
+
+		switch kind, detail := classifyFrame(message); kind {
+		case frameError:
+			return fmt.Errorf("binance stream error for %s: %s", name, detail)
+		case frameResult:
+			log.Printf("Binance control response: %s", detail)
+			continue
+		case frameInvalid:
+			log.Printf("Ignoring malformed frame: %s", detail)
+			continue
+		default:
+			if detail != "" && detail != streamEventName(stream) {
+				log.Printf("Ignoring unexpected %q event", detail)
+				continue
+			}
+		}
+
+		if price, qty, t, ok := parseTradeEvent(message); ok {
+			offer(ctx, out, TradeMessage{Symbol: tradeSymbol, Price: price, Quantity: qty, Time: t})
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// streamType is the Binance stream consumed for prices (STREAM_TYPE):
+// "trade", "aggTrade" or "kline_<interval>"
+var streamType = "trade"
+
+// Intervals Binance accepts for kline streams
+var klineIntervals = map[string]bool{
+	"1s": true, "1m": true, "3m": true, "5m": true, "15m": true, "30m": true,
+	"1h": true, "2h": true, "4h": true, "6h": true, "8h": true, "12h": true,
+	"1d": true, "3d": true, "1w": true, "1M": true,
+}
+
+func validStreamType(t string) bool {
+	if t == "trade" || t == "aggTrade" {
+		return true
+	}
+	interval, ok := strings.CutPrefix(t, "kline_")
+	return ok && klineIntervals[interval]
+}
+
+// streamEventName is the "e" field Binance sets on events of stream type t
+func streamEventName(t string) string {
+	if strings.HasPrefix(t, "kline_") {
+		return "kline"
+	}
+	return t
+}
+
+// BinanceKline is a kline update; "c" is the close so far, or the final
+// close once the candle ends. Event has to be decoded too: encoding/json
+// matches keys case-insensitively, so "e" would otherwise land in "E".
+type BinanceKline struct {
+	Event     string `json:"e"`
+	EventTime int64  `json:"E"`
+	Kline     struct {
+		Close string `json:"c"`
+	} `json:"k"`
+}
+
+// parseTradeEvent normalizes a trade, aggTrade or kline payload into a
+// price, quantity and time. trade and aggTrade share the "p"/"q"/"T"
+// fields; klines report their running close price at the event time, and
+// no quantity since their volume is cumulative over the candle.
+func parseTradeEvent(message []byte) (price, qty float64, t int64, ok bool) {
+	var raw, rawQty string
+	if streamEventName(streamType) == "kline" {
+		var k BinanceKline
+		if err := json.Unmarshal(message, &k); err != nil {
+			return 0, 0, 0, false
+		}
+		raw, t = k.Kline.Close, k.EventTime
+	} else {
+		var trade BinanceTrade
+		if err := json.Unmarshal(message, &trade); err != nil {
+			return 0, 0, 0, false
+		}
+		raw, rawQty, t = trade.Price, trade.Quantity, trade.Time
+	}
+
+	// Binance sends prices as strings; anything unparseable is dropped.
+	// A bad quantity only loses the quantity.
+	price, err := strconv.ParseFloat(raw, 64)
+	if err != nil || price <= 0 {
+		return 0, 0, 0, false
+	}
+	if rawQty != "" {
+		if q, err := strconv.ParseFloat(rawQty, 64); err == nil && q > 0 {
+			qty = q
+		}
+	}
+	return price, qty, t, true
+}
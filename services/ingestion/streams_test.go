@@ -0,0 +1,73 @@
+package main
+
+import "testing"
+
+// Binance payloads use keys differing only in case ("e"/"E", "t"/"T",
+// "m"/"M"), which encoding/json would match case-insensitively
+func TestParseTradeEvent(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		event  string
+		price  float64
+		qty    float64
+		time   int64
+		ok     bool
+	}{
+		{
+			name:   "trade",
+			stream: "trade",
+			event:  `{"e":"trade","E":1700000000123,"s":"BTCUSDT","t":12345,"p":"42000.10","q":"0.5","T":1700000000120,"m":true,"M":true}`,
+			price:  42000.10, qty: 0.5, time: 1700000000120, ok: true,
+		},
+		{
+			name:   "aggTrade",
+			stream: "aggTrade",
+			event:  `{"e":"aggTrade","E":1700000000123,"s":"BTCUSDT","a":7,"p":"42000.10","q":"0.25","f":1,"l":2,"T":1700000000120,"m":false,"M":true}`,
+			price:  42000.10, qty: 0.25, time: 1700000000120, ok: true,
+		},
+		{
+			name:   "kline",
+			stream: "kline_1m",
+			event:  `{"e":"kline","E":1700000000123,"s":"BTCUSDT","k":{"t":1699999980000,"T":1700000039999,"s":"BTCUSDT","i":"1m","o":"41990.00","c":"42000.10","h":"42010.00","l":"41980.00","v":"12.5","x":false}}`,
+			price:  42000.10, time: 1700000000123, ok: true,
+		},
+		{
+			name:   "bad quantity keeps the price",
+			stream: "trade",
+			event:  `{"e":"trade","E":1,"t":1,"p":"1.5","q":"x","T":2,"m":true,"M":true}`,
+			price:  1.5, time: 2, ok: true,
+		},
+		{
+			name:   "zero price",
+			stream: "trade",
+			event:  `{"e":"trade","E":1,"t":1,"p":"0","q":"1","T":2}`,
+		},
+		{
+			name:   "unparseable price",
+			stream: "trade",
+			event:  `{"e":"trade","E":1,"t":1,"p":"n/a","q":"1","T":2}`,
+		},
+		{
+			name:   "kline without a close",
+			stream: "kline_5m",
+			event:  `{"e":"kline","E":1,"k":{}}`,
+		},
+	}
+
+	saved := streamType
+	defer func() { streamType = saved }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streamType = tt.stream
+			price, qty, ts, ok := parseTradeEvent([]byte(tt.event))
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if price != tt.price || qty != tt.qty || ts != tt.time {
+				t.Errorf("got price %v qty %v time %d, want %v %v %d", price, qty, ts, tt.price, tt.qty, tt.time)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sync"
+)
+
+// trackedStreams streams trades for the active symbol (control.symbol) and
+// the tracked set (control.symbols), one connection per symbol. Changing
+// either only stops the streams for symbols no longer wanted and starts the
+// ones newly wanted; the rest keep their connections.
+type trackedStreams struct {
+	// newStream returns the connect function for one symbol's stream; each
+	// stream gets its own, so per-stream state like failure counts stays
+	// separate
+	newStream func() func(ctx context.Context, symbol string)
+
+	mu      sync.Mutex
+	ctx     context.Context // nil until run
+	active  string
+	tracked []string
+	running map[string]context.CancelFunc
+	failed  map[string]bool // streams that gave up (MAX_CONSECUTIVE_FAILURES)
+}
+
+func newTrackedStreams(active string, newStream func() func(ctx context.Context, symbol string)) *trackedStreams {
+	return &trackedStreams{
+		newStream: newStream,
+		active:    active,
+		running:   make(map[string]context.CancelFunc),
+		failed:    make(map[string]bool),
+	}
+}
+
+// run starts the wanted streams and keeps them going until ctx is cancelled
+func (t *trackedStreams) run(ctx context.Context) {
+	t.mu.Lock()
+	t.ctx = ctx
+	t.sync()
+	t.mu.Unlock()
+
+	<-ctx.Done()
+}
+
+// setActive switches the active symbol; it keeps streaming if it's also
+// tracked
+func (t *trackedStreams) setActive(symbol string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.active = symbol
+	t.sync()
+}
+
+// setTracked replaces the whole tracked set, and returns how many streams
+// were started and stopped as a result. An empty set leaves only the
+// active symbol.
+func (t *trackedStreams) setTracked(symbols []string) (started, stopped int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tracked = slices.Clone(symbols)
+	return t.sync()
+}
+
+// symbols lists what's being streamed, sorted
+func (t *trackedStreams) symbols() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]string, 0, len(t.running))
+	for sym := range t.running {
+		out = append(out, sym)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// giveUp records that symbol's stream has stopped retrying. It stays
+// stopped, and listed by failedSymbols, until the symbol is no longer
+// wanted; wanting it again starts a fresh stream.
+func (t *trackedStreams) giveUp(symbol string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.running[symbol]; ok {
+		t.failed[symbol] = true
+	}
+}
+
+// failedSymbols lists the streams that gave up, sorted
+func (t *trackedStreams) failedSymbols() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]string, 0, len(t.failed))
+	for sym := range t.failed {
+		out = append(out, sym)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// sync stops and starts streams to match the active symbol plus the
+// tracked set. Before run there's nothing to start. t.mu must be held.
+func (t *trackedStreams) sync() (started, stopped int) {
+	if t.ctx == nil {
+		return 0, 0
+	}
+	want := map[string]bool{t.active: true}
+	for _, sym := range t.tracked {
+		want[sym] = true
+	}
+
+	for sym, cancel := range t.running {
+		if !want[sym] {
+			cancel()
+			delete(t.running, sym)
+			delete(t.failed, sym)
+			log.Printf("Stopped streaming %s", sym)
+			stopped++
+		}
+	}
+	for sym := range want {
+		if _, ok := t.running[sym]; ok {
+			continue
+		}
+		ctx, cancel := context.WithCancel(t.ctx)
+		t.running[sym] = cancel
+		go runStreamLoop(ctx, newSymbolState(sym), t.newStream())
+		started++
+	}
+	return started, stopped
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"math"
+	"sync"
+)
+
+// Average true range over completed one-minute candles
+const (
+	atrPeriod   = 14
+	atrBarMilli = 60_000
+)
+
+// atrTracker builds 1m candles from trade times and keeps a Wilder-smoothed
+// ATR over the completed ones. Minutes without trades produce no candle.
+type atrTracker struct {
+	mu sync.Mutex
+
+	bucket    int64 // start of the candle being built, in ms; 0 before the first trade
+	high, low float64
+	close     float64
+
+	prevClose float64
+	bars      int     // completed candles
+	trSum     float64 // sum of true ranges until the first ATR is seeded
+	atr       float64
+}
+
+func newATRTracker() *atrTracker {
+	return &atrTracker{}
+}
+
+// add folds a trade into the current candle, closing it first if the trade
+// belongs to a later minute. Late trades count toward the current candle.
+func (a *atrTracker) add(price float64, timeMs int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	bucket := timeMs - timeMs%atrBarMilli
+	if a.bucket == 0 {
+		a.open(bucket, price)
+		return
+	}
+	if bucket > a.bucket {
+		a.complete()
+		a.open(bucket, price)
+		return
+	}
+	a.high = math.Max(a.high, price)
+	a.low = math.Min(a.low, price)
+	a.close = price
+}
+
+func (a *atrTracker) open(bucket int64, price float64) {
+	a.bucket = bucket
+	a.high, a.low, a.close = price, price, price
+}
+
+// complete closes the current candle into the ATR
+func (a *atrTracker) complete() {
+	tr := a.high - a.low
+	if a.bars > 0 {
+		tr = math.Max(tr, math.Max(math.Abs(a.high-a.prevClose), math.Abs(a.low-a.prevClose)))
+	}
+	a.prevClose = a.close
+	a.bars++
+
+	switch {
+	case a.bars < atrPeriod:
+		a.trSum += tr
+	case a.bars == atrPeriod:
+		a.atr = (a.trSum + tr) / atrPeriod
+	default:
+		a.atr = (a.atr*(atrPeriod-1) + tr) / atrPeriod
+	}
+}
+
+// value returns the ATR, or false until atrPeriod candles have completed
+func (a *atrTracker) value() (float64, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.atr, a.bars >= atrPeriod
+}
+
+func (a *atrTracker) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.bucket, a.bars, a.trSum, a.atr = 0, 0, 0, 0
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+
+	"shared/msgcodec"
+	"shared/tracing"
+)
+
+// wireCodec is what this service publishes trades with (MSG_FORMAT)
+var wireCodec msgcodec.Codec = msgcodec.JSON{}
+
+var encodeBufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 256)
+		return &b
+	},
+}
+
+// publishMessage encodes v with wireCodec into a pooled buffer and
+// publishes it with its content type and ctx's trace headers. Publishing
+// copies the payload into the connection's write buffer, so the buffer can
+// go straight back to the pool.
+func publishMessage(ctx context.Context, nc *nats.Conn, subject string, v any) error {
+	bp := encodeBufPool.Get().(*[]byte)
+	defer encodeBufPool.Put(bp)
+
+	b, err := wireCodec.Append((*bp)[:0], v)
+	*bp = b
+	if err != nil {
+		return err
+	}
+	msg := &nats.Msg{Subject: subject, Data: b}
+	if ct := wireCodec.ContentType(); ct != msgcodec.ContentTypeJSON {
+		msg.Header = nats.Header{"Content-Type": []string{ct}}
+	}
+	return tracing.PublishMsg(ctx, nc, msg)
+}
+
+// appendJSONString appends s quoted; symbols are plain ASCII, anything
+// that would need escaping goes through encoding/json
+func appendJSONString(b []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c >= 0x80 || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' {
+			q, _ := json.Marshal(s)
+			return append(b, q...)
+		}
+	}
+	b = append(b, '"')
+	b = append(b, s...)
+	return append(b, '"')
+}
+
+// appendOptionalFloat appends key and *f, or nothing for nil (omitempty)
+func appendOptionalFloat(b []byte, key string, f *float64) []byte {
+	if f == nil {
+		return b
+	}
+	b = append(b, key...)
+	return appendJSONFloat(b, *f)
+}
+
+// appendFloatMap writes m as a JSON object. encoding/json sorts map keys
+// as strings ("21" before "9"), so this does too.
+func appendFloatMap(b []byte, m map[int]float64) []byte {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, strconv.Itoa(k))
+	}
+	slices.Sort(keys)
+	b = append(b, '{')
+	for i, k := range keys {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = strconv.AppendQuote(b, k)
+		b = append(b, ':')
+		n, _ := strconv.Atoi(k)
+		b = appendJSONFloat(b, m[n])
+	}
+	return append(b, '}')
+}
+
+// appendJSONFloat formats f the same way encoding/json does. NaN and Inf
+// have no JSON form and are written as null.
+func appendJSONFloat(b []byte, f float64) []byte {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return append(b, "null"...)
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	b = strconv.AppendFloat(b, f, format, -1, 64)
+	if format == 'e' {
+		// Clean up e-09 to e-9, as encoding/json does
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b
+}
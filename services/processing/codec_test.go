@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// fullMessage has every field of ProcessedMessage set
+func fullMessage() ProcessedMessage {
+	return ProcessedMessage{
+		Symbol:            "btcusdt",
+		Price:             42000.12,
+		RawPrice:          ptr(42000.1234),
+		Quantity:          0.00123,
+		MovingAverage:     ptr(41987.55),
+		High:              ptr(42100),
+		Low:               ptr(41800.5),
+		RollingHigh:       ptr(42050),
+		RollingLow:        ptr(41900.25),
+		ATR:               ptr(35.75),
+		VolWeightedChange: ptr(-0.00042),
+		MovingAverages:    map[int]float64{20: 41990.1, 50: 41950.2, 200: 41800.3},
+		Time:              1700000000120,
+		TraceID:           "Qn9ZYfIFxKqV0nIp4Qz3Jb",
+		Warmed:            true,
+		OutOfOrder:        true,
+		Transform:         &priceTransform{Scale: 1.5, Offset: -2},
+	}
+}
+
+func TestProcessedMessageAppendJSON(t *testing.T) {
+	checkProcessedJSON(t, fullMessage())
+	checkProcessedJSON(t, ProcessedMessage{Symbol: "ethusdt", Price: 1e-7})
+
+	// Random subsets of the optional fields, and every float format
+	// encoding/json might pick
+	rng := rand.New(rand.NewSource(1))
+	float := func() float64 {
+		return (rng.Float64() - 0.5) * math.Pow(10, float64(rng.Intn(50)-25))
+	}
+	opt := func() *float64 {
+		if rng.Intn(2) == 0 {
+			return nil
+		}
+		return ptr(float())
+	}
+	for i := 0; i < 20000; i++ {
+		m := ProcessedMessage{
+			Symbol:            "btcusdt",
+			Price:             float(),
+			RawPrice:          opt(),
+			MovingAverage:     opt(),
+			High:              opt(),
+			Low:               opt(),
+			RollingHigh:       opt(),
+			RollingLow:        opt(),
+			ATR:               opt(),
+			VolWeightedChange: opt(),
+			Time:              rng.Int63(),
+			Warmed:            rng.Intn(2) == 0,
+			OutOfOrder:        rng.Intn(2) == 0,
+		}
+		if rng.Intn(2) == 0 {
+			m.Quantity = float()
+		}
+		if rng.Intn(3) == 0 {
+			m.MovingAverages = map[int]float64{rng.Intn(500) + 1: float(), rng.Intn(500) + 1: float()}
+		}
+		checkProcessedJSON(t, m)
+	}
+}
+
+func checkProcessedJSON(t *testing.T, m ProcessedMessage) {
+	t.Helper()
+	want, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(m.AppendJSON(nil)); got != string(want) {
+		t.Fatalf("AppendJSON = %s\njson.Marshal = %s", got, want)
+	}
+}
+
+func BenchmarkProcessedMarshal(b *testing.B) {
+	m := fullMessage()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// The pooled-buffer path publishMessage takes
+func BenchmarkProcessedAppendJSON(b *testing.B) {
+	m := fullMessage()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		bp := encodeBufPool.Get().(*[]byte)
+		*bp = m.AppendJSON((*bp)[:0])
+		encodeBufPool.Put(bp)
+	}
+}
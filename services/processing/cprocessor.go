@@ -0,0 +1,31 @@
+//go:build cgo && !nocgo
+
+package main
+
+/*
+#cgo LDFLAGS: -L. -lprocess -lpthread -lstdc++
+#include "process.h"
+*/
+import "C"
+
+// cProcessor wraps the C++ library in process.cpp. Its state is global,
+// so there is only ever one.
+type cProcessor struct{}
+
+// newNativeProcessor returns the C++ processor, or nil in builds without it
+func newNativeProcessor() processor { return cProcessor{} }
+
+func (cProcessor) AddPrice(price float64) { C.add_price(C.double(price)) }
+func (cProcessor) MovingAverage() float64 { return float64(C.get_moving_average()) }
+func (cProcessor) StdDev() float64        { return float64(C.get_std_dev()) }
+func (cProcessor) SampleCount() int       { return int(C.get_sample_count()) }
+func (cProcessor) Window() int            { return C.BUFFER_SIZE }
+func (cProcessor) High() float64          { return float64(C.get_high()) }
+func (cProcessor) Low() float64           { return float64(C.get_low()) }
+func (cProcessor) Reset()                 { C.reset_processor() }
+func (cProcessor) RollingHigh(n int) float64 {
+	return float64(C.get_rolling_high(C.int(n)))
+}
+func (cProcessor) RollingLow(n int) float64 {
+	return float64(C.get_rolling_low(C.int(n)))
+}
@@ -0,0 +1,7 @@
+//go:build !cgo || nocgo
+
+package main
+
+// newNativeProcessor returns nil: this build has no C++ library (built with
+// -tags nocgo or CGO_ENABLED=0), so the pure-Go processor is used
+func newNativeProcessor() processor { return nil }
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Crossover directions
+const (
+	crossGolden = "golden" // fast MA crossed above the slow one
+	crossDeath  = "death"  // fast MA crossed below the slow one
+)
+
+// CrossoverEvent is published on events.crossover when the fast moving
+// average crosses the slow one
+type CrossoverEvent struct {
+	Symbol     string  `json:"symbol"`
+	Direction  string  `json:"direction"` // golden or death
+	FastWindow int     `json:"fast_window"`
+	SlowWindow int     `json:"slow_window"`
+	FastMA     float64 `json:"fast_ma"`
+	SlowMA     float64 `json:"slow_ma"`
+	Price      float64 `json:"price"`
+	Time       int64   `json:"time"`
+	TraceID    string  `json:"trace_id,omitempty"`
+}
+
+// parseCrossoverWindows reads CROSSOVER_WINDOWS ("9,21": fast, slow, both
+// in MA_WINDOWS). Empty uses MA_WINDOWS' smallest and largest, or 0, 0
+// (off) with fewer than two.
+func parseCrossoverWindows(v string, maWindows []int) (fast, slow int, err error) {
+	if v == "" {
+		if len(maWindows) < 2 {
+			return 0, 0, nil
+		}
+		return maWindows[0], maWindows[len(maWindows)-1], nil
+	}
+	parts := strings.Split(v, ",")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("want fast,slow windows, e.g. 9,21")
+	}
+	fast, err1 := strconv.Atoi(strings.TrimSpace(parts[0]))
+	slow, err2 := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err1 != nil || err2 != nil || fast >= slow {
+		return 0, 0, fmt.Errorf("want fast,slow windows with fast < slow, e.g. 9,21")
+	}
+	if !slices.Contains(maWindows, fast) || !slices.Contains(maWindows, slow) {
+		return 0, 0, fmt.Errorf("windows %d and %d must both be in MA_WINDOWS", fast, slow)
+	}
+	return fast, slow, nil
+}
+
+// crossoverDetector reports the fast MA crossing the slow one, once both
+// windows are full and at most once per debounce period of trade time
+type crossoverDetector struct {
+	fast, slow int
+	debounce   time.Duration
+
+	mu        sync.Mutex
+	reported  int   // +1 fast above slow, -1 below, as of the last event or first reading
+	lastEvent int64 // trade time of the last event, unix ms
+}
+
+func newCrossoverDetector(fast, slow int, debounce time.Duration) *crossoverDetector {
+	return &crossoverDetector{fast: fast, slow: slow, debounce: debounce}
+}
+
+// update takes the latest averages and the trade's time, and reports a
+// crossover if one is due. A nil detector (crossovers off) never does.
+func (c *crossoverDetector) update(mas *multiMA, t int64) (dir string, fastMA, slowMA float64, ok bool) {
+	if c == nil || !mas.full(c.slow) {
+		return "", 0, 0, false
+	}
+	values := mas.values()
+	fastMA, slowMA = values[c.fast], values[c.slow]
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var side int
+	switch {
+	case fastMA > slowMA:
+		side = 1
+	case fastMA < slowMA:
+		side = -1
+	default:
+		// Touching isn't crossing; wait for a side
+		return "", 0, 0, false
+	}
+	if c.reported == 0 {
+		// First reading: nothing has crossed yet
+		c.reported = side
+		return "", 0, 0, false
+	}
+	if side == c.reported || (c.lastEvent != 0 && t-c.lastEvent < c.debounce.Milliseconds()) {
+		return "", 0, 0, false
+	}
+
+	c.reported = side
+	c.lastEvent = t
+	dir = crossDeath
+	if side > 0 {
+		dir = crossGolden
+	}
+	return dir, fastMA, slowMA, true
+}
+
+func (c *crossoverDetector) reset() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reported, c.lastEvent = 0, 0
+}
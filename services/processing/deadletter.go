@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nats-io/nats.go"
+)
+
+// dlqSubject receives undecodable messages (DLQ_SUBJECT)
+var dlqSubject = "trades.dlq"
+
+// dlqCount counts dead-lettered messages, for /healthz
+var dlqCount atomic.Uint64
+
+// DeadLetter is published on dlqSubject for a message processing couldn't
+// decode. Text payloads are kept as-is; binary ones (e.g. malformed
+// MessagePack) are base64-encoded.
+type DeadLetter struct {
+	Subject       string `json:"subject"`
+	Reason        string `json:"reason"`
+	ContentType   string `json:"content_type,omitempty"`
+	Payload       string `json:"payload,omitempty"`
+	PayloadBase64 []byte `json:"payload_base64,omitempty"`
+	Time          int64  `json:"time"`
+}
+
+// deadLetter publishes msg to dlqSubject with the reason it was rejected.
+// Decoding is deterministic, so there's no retry: the message goes
+// straight to the dead-letter subject for inspection.
+func deadLetter(nc *nats.Conn, clock Clock, msg *nats.Msg, reason error) {
+	data, _ := json.Marshal(newDeadLetter(msg, reason, clock.Now()))
+	nc.Publish(dlqSubject, data)
+
+	// Log a sample rather than every occurrence
+	if n := dlqCount.Add(1); n == 1 || n%100 == 0 {
+		log.Printf("Undecodable %s message sent to %s (%d so far): %v", msg.Subject, dlqSubject, n, reason)
+	}
+}
+
+func newDeadLetter(msg *nats.Msg, reason error, now time.Time) DeadLetter {
+	dl := DeadLetter{
+		Subject:     msg.Subject,
+		Reason:      reason.Error(),
+		ContentType: msg.Header.Get("Content-Type"),
+		Time:        now.UnixMilli(),
+	}
+	if utf8.Valid(msg.Data) {
+		dl.Payload = string(msg.Data)
+	} else {
+		dl.PayloadBase64 = msg.Data
+	}
+	return dl
+}
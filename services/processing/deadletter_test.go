@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestNewDeadLetter(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+	reason := errors.New("bad trade")
+
+	text := newDeadLetter(&nats.Msg{Subject: "trades.raw", Data: []byte(`{"price":`)}, reason, clock.Now())
+	want := DeadLetter{Subject: "trades.raw", Reason: "bad trade", Payload: `{"price":`, Time: clock.Now().UnixMilli()}
+	if text.Subject != want.Subject || text.Reason != want.Reason || text.Payload != want.Payload || text.PayloadBase64 != nil || text.Time != want.Time {
+		t.Errorf("text payload: got %+v, want %+v", text, want)
+	}
+
+	msg := nats.NewMsg("trades.raw")
+	msg.Header.Set("Content-Type", "application/msgpack")
+	msg.Data = []byte{0xff, 0xfe}
+	binary := newDeadLetter(msg, reason, clock.Now())
+	if binary.Payload != "" || string(binary.PayloadBase64) != "\xff\xfe" || binary.ContentType != "application/msgpack" {
+		t.Errorf("binary payload: got %+v", binary)
+	}
+}
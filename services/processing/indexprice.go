@@ -0,0 +1,59 @@
+package main
+
+import (
+	"sync"
+
+	"shared/indexdef"
+)
+
+// indexBase is an index's value when every constituent is at its base price
+const indexBase = 100.0
+
+// indexPricer values an index from its constituents' latest prices. Each
+// constituent's first price after a reset is its base, so constituents
+// contribute by relative move rather than by price level: an equal-weight
+// BTC+SOL basket isn't dominated by BTC. Constituents with no trade yet are
+// left out and the remaining weights rescaled.
+type indexPricer struct {
+	mu   sync.Mutex
+	def  *indexdef.Def
+	base map[string]float64
+	last map[string]float64
+}
+
+func newIndexPricer(def *indexdef.Def) *indexPricer {
+	return &indexPricer{def: def, base: make(map[string]float64), last: make(map[string]float64)}
+}
+
+// has reports whether symbol is one of the index's constituents
+func (p *indexPricer) has(symbol string) bool {
+	_, ok := p.def.Weights[symbol]
+	return ok
+}
+
+// update records a constituent trade and returns the new index value
+func (p *indexPricer) update(symbol string, price float64) float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.base[symbol]; !ok {
+		p.base[symbol] = price
+	}
+	p.last[symbol] = price
+
+	var sum, weights float64
+	for sym, last := range p.last {
+		w := p.def.Weights[sym]
+		sum += w * last / p.base[sym]
+		weights += w
+	}
+	return indexBase * sum / weights
+}
+
+// reset forgets base prices, so the index restarts at indexBase
+func (p *indexPricer) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	clear(p.base)
+	clear(p.last)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Indicators selectable with INDICATORS; each maps to ProcessedMessage fields
+var knownIndicators = map[string]string{
+	"sma":     "moving_average",
+	"hilo":    "high, low",
+	"rolling": "rolling_high, rolling_low",
+	"atr":     "atr",
+	"vwc":     "vol_weighted_change",
+	"multima": "moving_averages",
+}
+
+// IndicatorInfo describes one enabled indicator for discovery clients
+type IndicatorInfo struct {
+	Name   string         `json:"name"`
+	Fields []string       `json:"fields"`
+	Params map[string]int `json:"params"`
+	Unit   string         `json:"unit"`
+}
+
+// indicatorSet holds the enabled indicators
+type indicatorSet map[string]bool
+
+// parseIndicators reads a comma-separated list such as "sma,rolling".
+// Empty or "all" enables everything.
+func parseIndicators(v string) (indicatorSet, error) {
+	set := make(indicatorSet)
+	if v == "" || v == "all" {
+		for name := range knownIndicators {
+			set[name] = true
+		}
+		return set, nil
+	}
+	for _, name := range strings.Split(v, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := knownIndicators[name]; !ok {
+			return nil, fmt.Errorf("unknown indicator %q (known: sma, hilo, rolling, atr, vwc, multima)", name)
+		}
+		set[name] = true
+	}
+	return set, nil
+}
+
+// fill computes only the enabled indicators into m
+func (set indicatorSet) fill(m *ProcessedMessage, proc processor, atr *atrTracker, vwc *vwcTracker, mas *multiMA, rollingWindow int) {
+	if set["sma"] {
+		m.MovingAverage = ptr(proc.MovingAverage())
+	}
+	if set["hilo"] {
+		m.High = ptr(proc.High())
+		m.Low = ptr(proc.Low())
+	}
+	if set["rolling"] {
+		m.RollingHigh = ptr(proc.RollingHigh(rollingWindow))
+		m.RollingLow = ptr(proc.RollingLow(rollingWindow))
+	}
+	if set["atr"] {
+		if v, ok := atr.value(); ok {
+			m.ATR = ptr(v)
+		}
+	}
+	if set["vwc"] {
+		if v, ok := vwc.value(); ok {
+			m.VolWeightedChange = ptr(v)
+		}
+	}
+	if set["multima"] {
+		m.MovingAverages = mas.values()
+	}
+}
+
+// describe lists the enabled indicators with their current parameters, in
+// a stable order. multima is only listed when MA_WINDOWS sets some windows.
+func (set indicatorSet) describe(maWindow, rollingWindow, vwcWindow int, maWindows []int) []IndicatorInfo {
+	multiParams := make(map[string]int, len(maWindows))
+	for i, w := range maWindows {
+		multiParams["window_"+strconv.Itoa(i+1)] = w
+	}
+
+	all := []IndicatorInfo{
+		{Name: "sma", Fields: []string{"moving_average"}, Params: map[string]int{"window": maWindow}, Unit: "quote"},
+		{Name: "hilo", Fields: []string{"high", "low"}, Params: map[string]int{}, Unit: "quote"},
+		{Name: "rolling", Fields: []string{"rolling_high", "rolling_low"}, Params: map[string]int{"window": rollingWindow}, Unit: "quote"},
+		{Name: "atr", Fields: []string{"atr"}, Params: map[string]int{"period": atrPeriod, "bar_seconds": atrBarMilli / 1000}, Unit: "quote"},
+		{Name: "vwc", Fields: []string{"vol_weighted_change"}, Params: map[string]int{"window": vwcWindow}, Unit: "quote"},
+		{Name: "multima", Fields: []string{"moving_averages"}, Params: multiParams, Unit: "quote"},
+	}
+	out := []IndicatorInfo{}
+	for _, info := range all {
+		if info.Name == "multima" && len(maWindows) == 0 {
+			continue
+		}
+		if set[info.Name] {
+			out = append(out, info)
+		}
+	}
+	return out
+}
+
+func ptr(f float64) *float64 { return &f }
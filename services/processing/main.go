@@ -1,19 +1,25 @@
 package main
 
-/*
-#cgo LDFLAGS: -L. -lprocess -lpthread -lstdc++
-#include "process.h"
-*/
-import "C"
-
 import (
+	"context"
 	"encoding/json"
 	"log"
+	"math"
 	"os"
+	"os/signal"
+	"strconv"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"shared/indexdef"
+	"shared/msgcodec"
+	"shared/natsconn"
+	"shared/tracing"
 )
 
 var (
@@ -23,34 +29,203 @@ var (
 
 // TradeMessage from ingestion service
 type TradeMessage struct {
-	Symbol string  `json:"symbol"`
-	Price  float64 `json:"price"`
-	Time   int64   `json:"time"`
+	Symbol   string  `json:"symbol"`
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity,omitempty"`
+	Time     int64   `json:"time"`
+	TraceID  string  `json:"trace_id,omitempty"`
 }
 
-// ProcessedMessage published after C++ processing
+// ProcessedMessage published after C++ processing. Indicators not enabled
+// in INDICATORS are nil and left out of the payload.
 type ProcessedMessage struct {
-	Symbol        string  `json:"symbol"`
-	Price         float64 `json:"price"`
-	MovingAverage float64 `json:"moving_average"`
-	High          float64 `json:"high"`
-	Low           float64 `json:"low"`
-	Time          int64   `json:"time"`
+	Symbol            string          `json:"symbol"`
+	Price             float64         `json:"price"`
+	RawPrice          *float64        `json:"raw_price,omitempty"` // price before tick-size rounding, for coins with a tick_size
+	Quantity          float64         `json:"quantity,omitempty"`  // trade size in the base asset, when ingestion has it (not for indexes)
+	MovingAverage     *float64        `json:"moving_average,omitempty"`
+	High              *float64        `json:"high,omitempty"`
+	Low               *float64        `json:"low,omitempty"`
+	RollingHigh       *float64        `json:"rolling_high,omitempty"`
+	RollingLow        *float64        `json:"rolling_low,omitempty"`
+	ATR               *float64        `json:"atr,omitempty"`                 // 14-period over 1m candles, once 14 have closed
+	VolWeightedChange *float64        `json:"vol_weighted_change,omitempty"` // change over VWC_WINDOW trades, each move weighted by its quantity
+	MovingAverages    map[int]float64 `json:"moving_averages,omitempty"`     // average per MA_WINDOWS window, keyed by window
+	Time              int64           `json:"time"`
+	TraceID           string          `json:"trace_id,omitempty"`
+	Warmed            bool            `json:"warmed"`                 // moving-average window is full
+	OutOfOrder        bool            `json:"out_of_order,omitempty"` // older than a trade already processed (OUT_OF_ORDER=flag)
+	Transform         *priceTransform `json:"transform,omitempty"`    // PRICE_SCALE/PRICE_OFFSET applied to price
 }
 
 func main() {
+	if os.Getenv(selfCheckEnv) == "1" {
+		selfCheckMain()
+	}
+
 	natsURL := os.Getenv("NATS_URL")
 	if natsURL == "" {
 		natsURL = "nats://localhost:4222"
 	}
 
-	log.Println("Processing service starting...")
+	// Lookback for rolling high/low, independent of the moving-average window
+	rollingWindow := 100
+	if v := os.Getenv("ROLLING_WINDOW"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 10000 {
+			log.Fatalf("Invalid ROLLING_WINDOW %q (must be 1-10000)", v)
+		}
+		rollingWindow = n
+	}
+
+	// Flag ticks more than K standard deviations from the moving average
+	spikeK := 3.0
+	if v := os.Getenv("SPIKE_K"); v != "" {
+		k, err := strconv.ParseFloat(v, 64)
+		if err != nil || k < 0 {
+			log.Fatalf("Invalid SPIKE_K %q (0 disables)", v)
+		}
+		spikeK = k
+	}
+
+	// Trades in the volume-adjusted change window
+	vwcWindow := 100
+	if v := os.Getenv("VWC_WINDOW"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 2 || n > 10000 {
+			log.Fatalf("Invalid VWC_WINDOW %q (must be 2-10000)", v)
+		}
+		vwcWindow = n
+	}
+
+	// Extra moving-average windows computed side by side, e.g. 9,21,50
+	maWindows, err := parseMAWindows(os.Getenv("MA_WINDOWS"))
+	if err != nil {
+		log.Fatalf("Invalid MA_WINDOWS: %v", err)
+	}
+
+	// Publish golden/death crosses between two of those windows, at most
+	// once per CROSSOVER_DEBOUNCE
+	var crossover *crossoverDetector
+	fastWindow, slowWindow, err := parseCrossoverWindows(os.Getenv("CROSSOVER_WINDOWS"), maWindows)
+	if err != nil {
+		log.Fatalf("Invalid CROSSOVER_WINDOWS: %v", err)
+	}
+	if fastWindow > 0 {
+		debounce := 30 * time.Second
+		if v := os.Getenv("CROSSOVER_DEBOUNCE"); v != "" {
+			debounce, err = time.ParseDuration(v)
+			if err != nil || debounce < 0 {
+				log.Fatalf("Invalid CROSSOVER_DEBOUNCE %q", v)
+			}
+		}
+		crossover = newCrossoverDetector(fastWindow, slowWindow, debounce)
+		log.Printf("Detecting MA crossovers: %d over %d trades (debounce %s)", fastWindow, slowWindow, debounce)
+	}
+
+	indicators, err := parseIndicators(os.Getenv("INDICATORS"))
+	if err != nil {
+		log.Fatalf("Invalid INDICATORS: %v", err)
+	}
+
+	orderMode, err := parseOrderMode(os.Getenv("OUT_OF_ORDER"))
+	if err != nil {
+		log.Fatalf("Invalid OUT_OF_ORDER: %v", err)
+	}
+	order := newOrderGuard()
+
+	transform, err := parsePriceTransform(os.Getenv("PRICE_SCALE"), os.Getenv("PRICE_OFFSET"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if transform != nil {
+		log.Printf("Transforming prices: price * %g + %g", transform.Scale, transform.Offset)
+	}
+
+	// Published prices are rounded to the tick size of coins that have one
+	// in the API's COINS_FILE
+	ticks, err := loadTickSizes(os.Getenv("COINS_FILE"))
+	if err != nil {
+		log.Fatalf("Failed to load tick sizes from COINS_FILE: %v", err)
+	}
+
+	// Optionally hold off consuming trades until the rest of the pipeline is up
+	var startupDelay time.Duration
+	if v := os.Getenv("STARTUP_DELAY"); v != "" {
+		startupDelay, err = time.ParseDuration(v)
+		if err != nil || startupDelay < 0 {
+			log.Fatalf("Invalid STARTUP_DELAY %q (e.g. 10s)", v)
+		}
+	}
+	waitForReady := os.Getenv("WAIT_FOR_READY") == "true"
+
+	// Messages trades.raw may queue before NATS drops them as a slow
+	// consumer. Even at a few thousand trades/s this is tens of seconds of
+	// backlog; past that the prices are too stale to be worth keeping.
+	pendingLimit := 65536
+	if v := os.Getenv("PENDING_LIMIT"); v != "" {
+		pendingLimit, err = strconv.Atoi(v)
+		if err != nil || pendingLimit <= 0 {
+			log.Fatalf("Invalid PENDING_LIMIT %q (messages, > 0)", v)
+		}
+	}
+
+	if wireCodec, err = msgcodec.Parse(os.Getenv("MSG_FORMAT")); err != nil {
+		log.Fatalf("Invalid MSG_FORMAT: %v", err)
+	}
+
+	// Synthetic symbols priced from a basket of real ones
+	indexDefs, err := indexdef.Parse(os.Getenv("INDEXES"))
+	if err != nil {
+		log.Fatalf("Invalid INDEXES: %v", err)
+	}
+	indexes := make(map[string]*indexPricer, len(indexDefs))
+	for name, def := range indexDefs {
+		indexes[name] = newIndexPricer(def)
+	}
+
+	if v := os.Getenv("DLQ_SUBJECT"); v != "" {
+		dlqSubject = v
+	}
+
+	var clock Clock = realClock{}
+
+	// Per-symbol cap on trades processed, against a source flooding one
+	// symbol
+	if v := os.Getenv("MAX_TRADES_PER_SEC"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil || rate < 0 || math.IsInf(rate, 0) {
+			log.Fatalf("Invalid MAX_TRADES_PER_SEC %q (0 for no limit)", v)
+		}
+		tradeLimiter = newRateLimiter(rate, clock)
+	}
+
+	// Hold back trades.processed until the moving-average window is full,
+	// instead of publishing them flagged warmed:false
+	warmup := warmupGate{suppress: os.Getenv("SUPPRESS_UNTIL_WARM") == "true"}
+
+	procMode := processorAuto
+	if v := os.Getenv("PROCESSOR"); v != "" {
+		if v != processorAuto && v != processorNative && v != processorGo {
+			log.Fatalf("Invalid PROCESSOR %q (auto, native or go)", v)
+		}
+		procMode = v
+	}
+
+	log.Printf("Processing service %s (%s) starting (rolling window: %d trades, spike K: %g)...", version, commit, rollingWindow, spikeK)
+
+	proc := newProcessor(procMode)
+	atr := newATRTracker()
+	vwc := newVWCTracker(vwcWindow)
+	mas := newMultiMA(maWindows)
+
+	shutdownTracing := tracing.Init(context.Background(), "processing")
 
 	// Connect to NATS with retry
+	natsOpts := append([]nats.Option{nats.ErrorHandler(natsErrHandler)}, natsconn.Options()...)
 	var nc *nats.Conn
-	var err error
 	for i := 0; i < 10; i++ {
-		nc, err = nats.Connect(natsURL)
+		nc, err = nats.Connect(natsURL, natsOpts...)
 		if err == nil {
 			break
 		}
@@ -63,6 +238,10 @@ func main() {
 	defer nc.Close()
 	log.Println("Connected to NATS")
 
+	if addr := os.Getenv("HEALTH_ADDR"); addr != "" {
+		startHealthServer(addr, nc)
+	}
+
 	// Subscribe to symbol change for processor reset
 	nc.Subscribe("control.symbol", func(msg *nats.Msg) {
 		var req struct {
@@ -74,44 +253,261 @@ func main() {
 		symbolMu.Lock()
 		currentSymbol = req.Symbol
 		symbolMu.Unlock()
-		C.reset_processor()
+		proc.Reset()
+		atr.reset()
+		vwc.reset()
+		mas.reset()
+		crossover.reset()
+		if index := indexes[req.Symbol]; index != nil {
+			index.reset()
+		}
 		log.Printf("Processor reset for symbol change to %s", req.Symbol)
 	})
 
-	// Subscribe to raw trades
-	nc.Subscribe("trades.raw", func(msg *nats.Msg) {
+	// Manual reset (e.g. after a bad print) that keeps the current symbol
+	nc.Subscribe("control.reset", func(msg *nats.Msg) {
+		proc.Reset()
+		atr.reset()
+		vwc.reset()
+		mas.reset()
+		crossover.reset()
+		symbolMu.RLock()
+		log.Printf("Processor reset on request (symbol %s)", currentSymbol)
+		symbolMu.RUnlock()
+	})
+
+	// Handle raw trades, subscribed once the startup gate opens
+	var tradeMu sync.Mutex // trades held back by the rate limiter are released from a timer
+	processTrade := func(msg *nats.Msg, released bool) {
 		var trade TradeMessage
-		if err := json.Unmarshal(msg.Data, &trade); err != nil {
+		if err := msgcodec.Decode(msg, &trade); err != nil {
+			deadLetter(nc, clock, msg, err)
 			return
 		}
 
-		// Ignore trades from old symbol after a symbol change
+		tradeMu.Lock()
+		defer tradeMu.Unlock()
+
+		ctx, span := tracing.Tracer.Start(tracing.ContextFromMsg(msg), "process",
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				attribute.String("symbol", trade.Symbol),
+				attribute.String("trace_id", trade.TraceID),
+			))
+		defer span.End()
+
+		// Ignore trades from old symbol after a symbol change. An index
+		// takes trades for any of its constituents.
 		symbolMu.RLock()
 		sym := currentSymbol
 		symbolMu.RUnlock()
-		if sym != "" && trade.Symbol != sym {
+		index := indexes[sym]
+		if index != nil {
+			if !index.has(trade.Symbol) {
+				return
+			}
+		} else if sym != "" && trade.Symbol != sym {
+			return
+		}
+
+		// A NaN or infinite price would poison every running sum, and
+		// json.Marshal fails on one
+		if !validPrice(trade.Price) {
+			rejectPrice("raw", trade.Symbol, trade.Price)
+			return
+		}
+		// A released trade already took its token
+		if !released && !tradeLimiter.allow(trade.Symbol, msg) {
+			return
+		}
+
+		// Trades can arrive with timestamps older than ones already seen
+		var late int64
+		if orderMode != orderOff {
+			late = order.check(trade.Symbol, trade.Time)
+		}
+		if late > 0 {
+			// Log a sample rather than every occurrence
+			if n := outOfOrderCount.Add(1); n == 1 || n%100 == 0 {
+				log.Printf("Out-of-order trade on %s: %dms behind the newest (%d so far, %s)", trade.Symbol, late, n, orderMode)
+			}
+			if orderMode == orderDrop {
+				return
+			}
+		}
+
+		// A constituent trade moves the index, which from here on is
+		// processed like any other symbol
+		if index != nil {
+			trade.Price = index.update(trade.Symbol, trade.Price)
+			trade.Symbol = sym
+			trade.Quantity = 0
+		}
+
+		// Indicators, spikes and the published price all use the
+		// transformed price
+		trade.Price = transform.apply(trade.Price)
+		if !validPrice(trade.Price) {
+			rejectPrice("transformed", trade.Symbol, trade.Price)
 			return
 		}
 
-		// Process through C++
-		C.add_price(C.double(trade.Price))
+		// Score the tick against the window before it's included
+		if spikeK > 0 && warmed(proc) {
+			mean := proc.MovingAverage()
+			stddev := proc.StdDev()
+			if z, ok := detectSpike(trade.Price, mean, stddev, spikeK); ok {
+				data, _ := json.Marshal(SpikeEvent{
+					Symbol:  trade.Symbol,
+					Price:   trade.Price,
+					Mean:    mean,
+					StdDev:  stddev,
+					ZScore:  z,
+					Time:    trade.Time,
+					TraceID: trade.TraceID,
+				})
+				tracing.Publish(ctx, nc, "events.spike", data)
+				log.Printf("Spike on %s: %.8g is %.2f sigma from %.8g", trade.Symbol, trade.Price, z, mean)
+			}
+		}
+
+		// Process through C++ (or the Go fallback)
+		proc.AddPrice(trade.Price)
+		atr.add(trade.Price, trade.Time)
+		vwc.add(trade.Price, trade.Quantity)
+		mas.add(trade.Price)
+
+		if dir, fastMA, slowMA, ok := crossover.update(mas, trade.Time); ok {
+			data, _ := json.Marshal(CrossoverEvent{
+				Symbol:     trade.Symbol,
+				Direction:  dir,
+				FastWindow: fastWindow,
+				SlowWindow: slowWindow,
+				FastMA:     fastMA,
+				SlowMA:     slowMA,
+				Price:      trade.Price,
+				Time:       trade.Time,
+				TraceID:    trade.TraceID,
+			})
+			tracing.Publish(ctx, nc, "events.crossover", data)
+			log.Printf("MA crossover (%s) on %s: %d-trade MA %.8g vs %d-trade MA %.8g", dir, trade.Symbol, fastWindow, fastMA, slowWindow, slowMA)
+		}
 
 		// Get stats
 		processed := ProcessedMessage{
-			Symbol:        trade.Symbol,
-			Price:         trade.Price,
-			MovingAverage: float64(C.get_moving_average()),
-			High:          float64(C.get_high()),
-			Low:           float64(C.get_low()),
-			Time:          trade.Time,
+			Symbol:     trade.Symbol,
+			Price:      trade.Price,
+			Quantity:   trade.Quantity,
+			Time:       trade.Time,
+			TraceID:    trade.TraceID,
+			Warmed:     warmed(proc),
+			OutOfOrder: late > 0,
+			Transform:  transform,
+		}
+		indicators.fill(&processed, proc, atr, vwc, mas, rollingWindow)
+
+		// Indicators keep full precision; only the published price is
+		// rounded, with the unrounded one alongside
+		if rounded, ok := ticks.round(processed.Symbol, processed.Price); ok {
+			raw := processed.Price
+			processed.RawPrice = &raw
+			processed.Price = rounded
 		}
+		if !validPrice(processed.Price) {
+			// e.g. a tick size larger than the price rounds it to 0
+			rejectPrice("rounded", processed.Symbol, processed.Price)
+			return
+		}
+		logTrace("process", processed.TraceID, processed.Symbol, processed.Price)
+
+		if !warmup.publish(processed) {
+			return
+		}
+
+		publishMessage(ctx, nc, "trades.processed", processed)
+	}
+	handleTrade := func(msg *nats.Msg) { processTrade(msg, false) }
+	if tradeLimiter != nil {
+		tradeLimiter.handle = func(msg *nats.Msg) { processTrade(msg, true) }
+	}
 
-		data, _ := json.Marshal(processed)
-		nc.Publish("trades.processed", data)
+	// Announce the indicator config so the API can serve /api/indicators.
+	// Core NATS doesn't retain messages, so repeat it for late subscribers.
+	status, _ := json.Marshal(map[string]interface{}{
+		"indicators": indicators.describe(proc.Window(), rollingWindow, vwcWindow, maWindows),
+		"warmup":     proc.Window(),
+		"spike_k":    spikeK,
 	})
+	go func() {
+		for {
+			nc.Publish("status.indicators", status)
+			time.Sleep(30 * time.Second)
+		}
+	}()
 
-	log.Println("Processing service running, subscribed to trades.raw")
+	go func() {
+		waitForStart(nc, startupDelay, waitForReady)
+		sub, err := nc.Subscribe("trades.raw", handleTrade)
+		if err != nil {
+			log.Fatalf("Failed to subscribe to trades.raw: %v", err)
+		}
+		if err := sub.SetPendingLimits(pendingLimit, pendingLimit*1024); err != nil {
+			log.Printf("Failed to set trades.raw pending limits: %v", err)
+		}
+		consuming.Store(true)
+		log.Println("Processing service running, subscribed to trades.raw")
+	}()
+
+	// Run until SIGINT/SIGTERM, then flush any buffered spans
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	log.Println("Processing service shutting down")
 
-	// Keep running
-	select {}
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdownTracing(flushCtx)
+}
+
+// AppendJSON encodes the message without reflection; it matches json.Marshal
+func (m ProcessedMessage) AppendJSON(b []byte) []byte {
+	b = append(b, `{"symbol":`...)
+	b = appendJSONString(b, m.Symbol)
+	b = append(b, `,"price":`...)
+	b = appendJSONFloat(b, m.Price)
+	b = appendOptionalFloat(b, `,"raw_price":`, m.RawPrice)
+	if m.Quantity != 0 {
+		b = append(b, `,"quantity":`...)
+		b = appendJSONFloat(b, m.Quantity)
+	}
+	b = appendOptionalFloat(b, `,"moving_average":`, m.MovingAverage)
+	b = appendOptionalFloat(b, `,"high":`, m.High)
+	b = appendOptionalFloat(b, `,"low":`, m.Low)
+	b = appendOptionalFloat(b, `,"rolling_high":`, m.RollingHigh)
+	b = appendOptionalFloat(b, `,"rolling_low":`, m.RollingLow)
+	b = appendOptionalFloat(b, `,"atr":`, m.ATR)
+	b = appendOptionalFloat(b, `,"vol_weighted_change":`, m.VolWeightedChange)
+	if len(m.MovingAverages) > 0 {
+		b = append(b, `,"moving_averages":`...)
+		b = appendFloatMap(b, m.MovingAverages)
+	}
+	b = append(b, `,"time":`...)
+	b = strconv.AppendInt(b, m.Time, 10)
+	if m.TraceID != "" {
+		b = append(b, `,"trace_id":`...)
+		b = appendJSONString(b, m.TraceID)
+	}
+	b = append(b, `,"warmed":`...)
+	b = strconv.AppendBool(b, m.Warmed)
+	if m.OutOfOrder {
+		b = append(b, `,"out_of_order":true`...)
+	}
+	if m.Transform != nil {
+		b = append(b, `,"transform":{"scale":`...)
+		b = appendJSONFloat(b, m.Transform.Scale)
+		b = append(b, `,"offset":`...)
+		b = appendJSONFloat(b, m.Transform.Offset)
+		b = append(b, '}')
+	}
+	return append(b, '}')
 }
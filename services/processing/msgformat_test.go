@@ -0,0 +1,88 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"shared/msgcodec"
+)
+
+var codecs = []struct {
+	name  string
+	codec msgcodec.Codec
+}{
+	{"json", msgcodec.JSON{}},
+	{"msgpack", msgcodec.Msgpack{}},
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, c := range codecs {
+		codec := c.codec
+		t.Run(c.name, func(t *testing.T) {
+			want := fullMessage()
+			data, err := codec.Append(nil, want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got ProcessedMessage
+			if err := codec.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("round trip:\n got %+v\nwant %+v", got, want)
+			}
+
+			trade := TradeMessage{Symbol: "btcusdt", Price: 42000.12, Quantity: 0.5, Time: 1700000000120, TraceID: "Qn9ZYfIFxKqV0nIp4Qz3Jb"}
+			data, err = codec.Append(nil, trade)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gotTrade TradeMessage
+			if err := codec.Unmarshal(data, &gotTrade); err != nil {
+				t.Fatal(err)
+			}
+			if gotTrade != trade {
+				t.Errorf("trade round trip: got %+v, want %+v", gotTrade, trade)
+			}
+		})
+	}
+}
+
+// Encoding a full ProcessedMessage, with the encoded size as bytes/msg
+func BenchmarkCodecEncode(b *testing.B) {
+	m := fullMessage()
+	for _, c := range codecs {
+		codec := c.codec
+		b.Run(c.name, func(b *testing.B) {
+			var buf []byte
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var err error
+				if buf, err = codec.Append(buf[:0], m); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(buf)), "bytes/msg")
+		})
+	}
+}
+
+func BenchmarkCodecDecode(b *testing.B) {
+	for _, c := range codecs {
+		codec := c.codec
+		b.Run(c.name, func(b *testing.B) {
+			data, err := codec.Append(nil, fullMessage())
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var m ProcessedMessage
+				if err := codec.Unmarshal(data, &m); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(data)), "bytes/msg")
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxMAWindow bounds each MA_WINDOWS entry, like ROLLING_WINDOW
+const maxMAWindow = 10000
+
+// parseMAWindows reads MA_WINDOWS, e.g. "9,21,50", into ascending unique
+// window sizes. Empty means none.
+func parseMAWindows(v string) ([]int, error) {
+	if v == "" {
+		return nil, nil
+	}
+	var windows []int
+	for _, f := range strings.Split(v, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || n <= 0 || n > maxMAWindow {
+			return nil, fmt.Errorf("invalid window %q (must be 1-%d)", f, maxMAWindow)
+		}
+		windows = append(windows, n)
+	}
+	slices.Sort(windows)
+	return slices.Compact(windows), nil
+}
+
+// multiMA keeps a simple moving average per MA_WINDOWS window, as running
+// sums over one ring sized for the largest
+type multiMA struct {
+	mu      sync.Mutex
+	windows []int
+	sums    []float64
+	ring    []float64
+	next    int
+	count   int
+}
+
+func newMultiMA(windows []int) *multiMA {
+	m := &multiMA{windows: windows, sums: make([]float64, len(windows))}
+	if len(windows) > 0 {
+		m.ring = make([]float64, windows[len(windows)-1])
+	}
+	return m
+}
+
+func (m *multiMA) add(price float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.ring) == 0 {
+		return
+	}
+
+	size := len(m.ring)
+	for i, w := range m.windows {
+		if m.count >= w {
+			m.sums[i] -= m.ring[(m.next-w+size)%size]
+		}
+		m.sums[i] += price
+	}
+	m.ring[m.next] = price
+	m.next = (m.next + 1) % size
+	if m.count < size {
+		m.count++
+	}
+}
+
+// values returns each window's average, or nil before the first price or
+// with no windows configured
+func (m *multiMA) values() map[int]float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.count == 0 {
+		return nil
+	}
+	out := make(map[int]float64, len(m.windows))
+	for i, w := range m.windows {
+		out[w] = m.sums[i] / float64(min(m.count, w))
+	}
+	return out
+}
+
+// full reports whether the last w prices have all been seen
+func (m *multiMA) full(w int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.count >= w
+}
+
+func (m *multiMA) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	clear(m.ring)
+	clear(m.sums)
+	m.next, m.count = 0, 0
+}
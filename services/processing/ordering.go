@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// outOfOrderCount counts trades older than the newest already seen for
+// their symbol, for /healthz
+var outOfOrderCount atomic.Uint64
+
+// Out-of-order handling (OUT_OF_ORDER)
+const (
+	orderDrop = "drop" // skip late trades entirely
+	orderFlag = "flag" // process them, marked out_of_order
+	orderOff  = "off"  // don't check
+)
+
+func parseOrderMode(v string) (string, error) {
+	switch v {
+	case "":
+		return orderDrop, nil
+	case orderDrop, orderFlag, orderOff:
+		return v, nil
+	}
+	return "", fmt.Errorf("unknown OUT_OF_ORDER %q (drop, flag or off)", v)
+}
+
+// orderGuard remembers the newest trade time per symbol. Equal timestamps
+// are fine: several trades often share a millisecond.
+type orderGuard struct {
+	mu   sync.Mutex
+	last map[string]int64
+}
+
+func newOrderGuard() *orderGuard {
+	return &orderGuard{last: make(map[string]int64)}
+}
+
+// check records t for symbol and reports how far behind the newest seen
+// trade it is, or 0 when it's in order
+func (g *orderGuard) check(symbol string, t int64) int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	last := g.last[symbol]
+	if t < last {
+		return last - t
+	}
+	g.last[symbol] = t
+	return 0
+}
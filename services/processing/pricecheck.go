@@ -0,0 +1,31 @@
+package main
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+// maxPrice bounds accepted prices. Far below float64's range, but past it
+// the running sums behind the moving averages have no precision left.
+const maxPrice = 1e15
+
+// invalidPriceCount counts trades dropped for a NaN, infinite, non-positive
+// or too large price, for /healthz
+var invalidPriceCount atomic.Uint64
+
+// validPrice reports whether p can go into the indicators and out on
+// trades.processed. NaN fails every comparison and +Inf exceeds maxPrice,
+// so this also rules out both.
+func validPrice(p float64) bool {
+	return p > 0 && p <= maxPrice
+}
+
+// rejectPrice counts a trade dropped for an invalid price. stage says where
+// it was caught: the raw trade, after the index and PRICE_SCALE/PRICE_OFFSET
+// transform, or after tick rounding.
+func rejectPrice(stage, symbol string, price float64) {
+	// Log a sample rather than every occurrence
+	if n := invalidPriceCount.Add(1); n == 1 || n%100 == 0 {
+		log.Printf("Dropped %s trade with invalid %s price %v (%d so far)", symbol, stage, price, n)
+	}
+}
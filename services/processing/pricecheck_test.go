@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestValidPrice(t *testing.T) {
+	tests := []struct {
+		name  string
+		price float64
+		want  bool
+	}{
+		{"normal", 42000.12, true},
+		{"tiny", 1e-8, true},
+		{"smallest positive", math.SmallestNonzeroFloat64, true},
+		{"at the cap", maxPrice, true},
+		{"just over the cap", math.Nextafter(maxPrice, math.Inf(1)), false},
+		{"huge", 1e308, false},
+		{"max float", math.MaxFloat64, false},
+		{"zero", 0, false},
+		{"negative zero", math.Copysign(0, -1), false},
+		{"negative", -1, false},
+		{"NaN", math.NaN(), false},
+		{"+Inf", math.Inf(1), false},
+		{"-Inf", math.Inf(-1), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validPrice(tt.price); got != tt.want {
+				t.Errorf("validPrice(%v) = %v, want %v", tt.price, got, tt.want)
+			}
+		})
+	}
+}
+
+// A raw price can pass and the transformed one still fail, which is why
+// handleTrade checks both
+func TestValidPriceAfterTransform(t *testing.T) {
+	tests := []struct {
+		name      string
+		transform *priceTransform
+		price     float64
+		want      bool
+	}{
+		{"no transform", nil, 42000, true},
+		{"scaled", &priceTransform{Scale: 0.92}, 42000, true},
+		{"overflows to +Inf", &priceTransform{Scale: 1e300}, 1e10, false},
+		{"past the cap", &priceTransform{Scale: 1e6}, 42000 * 1e6, false},
+		{"offset below zero", &priceTransform{Scale: 1, Offset: -50}, 42, false},
+		{"offset to exactly zero", &priceTransform{Scale: 1, Offset: -42}, 42, false},
+		{"underflows to zero", &priceTransform{Scale: 1e-300}, 1e-30, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !validPrice(tt.price) {
+				t.Fatalf("raw price %v rejected", tt.price)
+			}
+			p := tt.transform.apply(tt.price)
+			if got := validPrice(p); got != tt.want {
+				t.Errorf("validPrice(%v) = %v, want %v", p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRejectPriceCounts(t *testing.T) {
+	before := invalidPriceCount.Load()
+	for _, p := range []float64{math.NaN(), math.Inf(1), math.Inf(-1), 1e308, 0} {
+		rejectPrice("raw", "btcusdt", p)
+	}
+	if n := invalidPriceCount.Load() - before; n != 5 {
+		t.Errorf("invalidPriceCount rose by %d, want 5", n)
+	}
+}
@@ -1,14 +1,20 @@
+//go:build cgo && !nocgo
+
 #include "process.h"
 #include <vector>
+#include <deque>
 #include <mutex>
 #include <limits>
+#include <algorithm>
+#include <cmath>
 
-// Buffer size for moving average calculation
-const int BUFFER_SIZE = 20;
+// Most trades kept for rolling high/low lookbacks
+const int MAX_ROLLING_WINDOW = 10000;
 
 // Thread-safe price processor
 static std::mutex mtx;
 static std::vector<double> price_buffer;
+static std::deque<double> rolling_buffer;
 static double high_price = 0.0;
 static double low_price = std::numeric_limits<double>::max();
 
@@ -30,6 +36,12 @@ void add_price(double price) {
         price_buffer.erase(price_buffer.begin());
     }
     price_buffer.push_back(price);
+
+    // Longer history for rolling extremes
+    if (rolling_buffer.size() >= MAX_ROLLING_WINDOW) {
+        rolling_buffer.pop_front();
+    }
+    rolling_buffer.push_back(price);
 }
 
 double get_moving_average(void) {
@@ -46,6 +58,31 @@ double get_moving_average(void) {
     return sum / price_buffer.size();
 }
 
+double get_std_dev(void) {
+    std::lock_guard<std::mutex> lock(mtx);
+
+    if (price_buffer.size() < 2) {
+        return 0.0;
+    }
+
+    double sum = 0.0;
+    for (double p : price_buffer) {
+        sum += p;
+    }
+    double mean = sum / price_buffer.size();
+
+    double sq = 0.0;
+    for (double p : price_buffer) {
+        sq += (p - mean) * (p - mean);
+    }
+    return std::sqrt(sq / price_buffer.size());
+}
+
+int get_sample_count(void) {
+    std::lock_guard<std::mutex> lock(mtx);
+    return static_cast<int>(price_buffer.size());
+}
+
 double get_high(void) {
     std::lock_guard<std::mutex> lock(mtx);
     return high_price;
@@ -60,9 +97,44 @@ double get_low(void) {
     return low_price;
 }
 
+double get_rolling_high(int window) {
+    std::lock_guard<std::mutex> lock(mtx);
+
+    if (rolling_buffer.empty() || window <= 0) {
+        return 0.0;
+    }
+
+    size_t n = std::min(static_cast<size_t>(window), rolling_buffer.size());
+    double high = rolling_buffer.back();
+    for (size_t i = rolling_buffer.size() - n; i < rolling_buffer.size(); i++) {
+        if (rolling_buffer[i] > high) {
+            high = rolling_buffer[i];
+        }
+    }
+    return high;
+}
+
+double get_rolling_low(int window) {
+    std::lock_guard<std::mutex> lock(mtx);
+
+    if (rolling_buffer.empty() || window <= 0) {
+        return 0.0;
+    }
+
+    size_t n = std::min(static_cast<size_t>(window), rolling_buffer.size());
+    double low = rolling_buffer.back();
+    for (size_t i = rolling_buffer.size() - n; i < rolling_buffer.size(); i++) {
+        if (rolling_buffer[i] < low) {
+            low = rolling_buffer[i];
+        }
+    }
+    return low;
+}
+
 void reset_processor(void) {
     std::lock_guard<std::mutex> lock(mtx);
     price_buffer.clear();
+    rolling_buffer.clear();
     high_price = 0.0;
     low_price = std::numeric_limits<double>::max();
 }
@@ -5,18 +5,33 @@
 extern "C" {
 #endif
 
+// Number of prices in the moving-average window
+#define BUFFER_SIZE 20
+
 // Add a new price to the buffer
 void add_price(double price);
 
 // Get the simple moving average of buffered prices
 double get_moving_average(void);
 
+// Get the population standard deviation of buffered prices
+double get_std_dev(void);
+
+// Get the number of prices in the moving-average buffer
+int get_sample_count(void);
+
 // Get the highest price seen
 double get_high(void);
 
 // Get the lowest price seen
 double get_low(void);
 
+// Get the highest price over the last `window` trades
+double get_rolling_high(int window);
+
+// Get the lowest price over the last `window` trades
+double get_rolling_low(int window);
+
 // Reset all data
 void reset_processor(void);
 
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// processor computes the running indicators. The native C++ library is
+// preferred; goProcessor is a pure-Go port used when it's unavailable.
+type processor interface {
+	AddPrice(price float64)
+	MovingAverage() float64
+	StdDev() float64
+	SampleCount() int
+	Window() int
+	High() float64
+	Low() float64
+	RollingHigh(window int) float64
+	RollingLow(window int) float64
+	Reset()
+}
+
+// Same sizes as BUFFER_SIZE and MAX_ROLLING_WINDOW in process.cpp
+const (
+	goMAWindow         = 20
+	goMaxRollingWindow = 10000
+)
+
+// goProcessor mirrors process.cpp so either can back the pipeline
+type goProcessor struct {
+	mu      sync.Mutex
+	prices  []float64 // moving-average window
+	rolling []float64 // longer history for rolling extremes
+	high    float64
+	low     float64
+	seen    bool
+}
+
+func newGoProcessor() *goProcessor {
+	return &goProcessor{}
+}
+
+func (p *goProcessor) AddPrice(price float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.seen || price > p.high {
+		p.high = price
+	}
+	if !p.seen || price < p.low {
+		p.low = price
+	}
+	p.seen = true
+
+	if len(p.prices) >= goMAWindow {
+		p.prices = p.prices[1:]
+	}
+	p.prices = append(p.prices, price)
+
+	if len(p.rolling) >= goMaxRollingWindow {
+		p.rolling = p.rolling[1:]
+	}
+	p.rolling = append(p.rolling, price)
+}
+
+func (p *goProcessor) MovingAverage() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return mean(p.prices)
+}
+
+func (p *goProcessor) StdDev() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.prices) < 2 {
+		return 0
+	}
+	m := mean(p.prices)
+	var sq float64
+	for _, v := range p.prices {
+		sq += (v - m) * (v - m)
+	}
+	return math.Sqrt(sq / float64(len(p.prices)))
+}
+
+func (p *goProcessor) SampleCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.prices)
+}
+
+func (p *goProcessor) Window() int { return goMAWindow }
+
+func (p *goProcessor) High() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.high
+}
+
+func (p *goProcessor) Low() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.low
+}
+
+func (p *goProcessor) RollingHigh(window int) float64 {
+	return p.rollingExtreme(window, func(a, b float64) bool { return a > b })
+}
+
+func (p *goProcessor) RollingLow(window int) float64 {
+	return p.rollingExtreme(window, func(a, b float64) bool { return a < b })
+}
+
+func (p *goProcessor) rollingExtreme(window int, better func(a, b float64) bool) float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.rolling) == 0 || window <= 0 {
+		return 0
+	}
+	tail := p.rolling[max(0, len(p.rolling)-window):]
+	best := tail[len(tail)-1]
+	for _, v := range tail {
+		if better(v, best) {
+			best = v
+		}
+	}
+	return best
+}
+
+func (p *goProcessor) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prices = nil
+	p.rolling = nil
+	p.high, p.low, p.seen = 0, 0, false
+}
+
+func mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range xs {
+		sum += v
+	}
+	return sum / float64(len(xs))
+}
+
+// Processor implementations for PROCESSOR
+const (
+	processorAuto   = "auto"   // the C++ one if it passes its self-check, else Go
+	processorNative = "native" // the C++ one, or exit
+	processorGo     = "go"     // the pure-Go port, without touching the C++ library
+)
+
+// newProcessor picks the implementation for mode (PROCESSOR). With auto it
+// falls back to goProcessor, loudly, when the native library is missing
+// from this build or fails the self-check.
+func newProcessor(mode string) processor {
+	if mode == processorGo {
+		log.Println("Using the pure-Go processor (PROCESSOR=go)")
+		return newGoProcessor()
+	}
+
+	native := newNativeProcessor()
+	if native == nil {
+		if mode == processorNative {
+			log.Fatal("PROCESSOR=native, but this build has no C++ processor")
+		}
+		log.Println("WARNING: built without the C++ processor; using the pure-Go fallback")
+		return newGoProcessor()
+	}
+	if err := checkNative(); err != nil {
+		if mode == processorNative {
+			log.Fatalf("C++ processor failed its self-check: %v", err)
+		}
+		log.Printf("WARNING: C++ processor failed its self-check (%v); using the pure-Go fallback", err)
+		return newGoProcessor()
+	}
+	log.Println("C++ processor passed self-check")
+	return native
+}
+
+// selfCheckEnv set to 1 makes the binary run the native self-check and exit
+// instead of starting the service; see checkNative
+const selfCheckEnv = "PROCESSING_SELF_CHECK"
+
+const selfCheckTimeout = 10 * time.Second
+
+// checkNative runs selfCheck in a child copy of this binary, since a fault
+// in the C++ code kills the process rather than panicking
+func checkNative() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), selfCheckTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, exe)
+	cmd.Env = append(os.Environ(), selfCheckEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		// The first line says what went wrong; a crash follows it with
+		// the runtime's stack dump
+		if line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n"); line != "" {
+			return fmt.Errorf("%v: %s", err, line)
+		}
+		return err
+	}
+	return nil
+}
+
+// selfCheckMain is main for the checkNative child: exit status 0 if the
+// C++ processor passes, 1 with the failure on stderr if it doesn't
+func selfCheckMain() {
+	native := newNativeProcessor()
+	if native == nil {
+		fmt.Fprintln(os.Stderr, "no C++ processor in this build")
+		os.Exit(1)
+	}
+	if err := selfCheck(native); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// selfCheck feeds p known prices and verifies the results, catching a
+// native library that loads but computes garbage. p is reset afterwards.
+// It runs in checkNative's child, which also catches the library crashing.
+func selfCheck(p processor) error {
+	defer p.Reset()
+
+	p.Reset()
+	for _, v := range []float64{100, 200, 300} {
+		p.AddPrice(v)
+	}
+
+	checks := []struct {
+		name      string
+		got, want float64
+	}{
+		{"moving average", p.MovingAverage(), 200},
+		{"high", p.High(), 300},
+		{"low", p.Low(), 100},
+		{"rolling high", p.RollingHigh(2), 300},
+		{"rolling low", p.RollingLow(2), 200},
+		{"std dev", p.StdDev(), math.Sqrt(20000.0 / 3)},
+		{"sample count", float64(p.SampleCount()), 3},
+	}
+	for _, c := range checks {
+		if math.Abs(c.got-c.want) > 1e-9 {
+			return fmt.Errorf("%s: got %g, want %g", c.name, c.got, c.want)
+		}
+	}
+	return nil
+}
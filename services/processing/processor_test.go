@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// selfCheckCrashEnv makes the checkNative child crash the way a faulting
+// native library would
+const selfCheckCrashEnv = "SELF_CHECK_TEST_CRASH"
+
+// checkNative's child is this test binary, started again with selfCheckEnv
+func TestMain(m *testing.M) {
+	if os.Getenv(selfCheckEnv) == "1" {
+		if os.Getenv(selfCheckCrashEnv) == "1" {
+			syscall.Kill(os.Getpid(), syscall.SIGSEGV)
+			time.Sleep(time.Second)
+		}
+		selfCheckMain()
+	}
+	os.Exit(m.Run())
+}
+
+// brokenProcessor loads fine but computes the wrong moving average
+type brokenProcessor struct{ *goProcessor }
+
+func (brokenProcessor) MovingAverage() float64 { return 0 }
+
+func TestSelfCheck(t *testing.T) {
+	if err := selfCheck(newGoProcessor()); err != nil {
+		t.Errorf("Go processor: %v", err)
+	}
+	if err := selfCheck(brokenProcessor{newGoProcessor()}); err == nil {
+		t.Error("broken processor passed")
+	}
+}
+
+func TestCheckNative(t *testing.T) {
+	if newNativeProcessor() == nil {
+		t.Skip("built without the C++ processor")
+	}
+	if err := checkNative(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckNativeCrash(t *testing.T) {
+	t.Setenv(selfCheckCrashEnv, "1")
+	err := checkNative()
+	if err == nil {
+		t.Fatal("crashed self-check reported no error")
+	}
+	t.Log(err)
+}
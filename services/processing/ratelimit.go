@@ -0,0 +1,122 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// tradeLimiter is the MAX_TRADES_PER_SEC limiter, nil when there's no limit
+var tradeLimiter *rateLimiter
+
+// rateLimiter is a per-symbol token bucket (MAX_TRADES_PER_SEC) that holds
+// back the newest trade over the limit instead of dropping it
+type rateLimiter struct {
+	rate   float64 // tokens per second
+	burst  float64
+	clock  Clock
+	handle func(*nats.Msg) // processes a released trade, which already has its token; set before the first trade
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens  float64
+	last    time.Time
+	held    *nats.Msg // newest trade waiting for a token
+	timer   Timer     // set while a trade is held
+	dropped uint64
+}
+
+// newRateLimiter returns nil (no limit) for a rate of 0
+func newRateLimiter(rate float64, clock Clock) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		rate:    rate,
+		burst:   max(rate, 1),
+		clock:   clock,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow takes a token for a trade on symbol, or holds msg back and reports
+// false. A nil limiter allows everything.
+func (l *rateLimiter) allow(symbol string, msg *nats.Msg) bool {
+	if l == nil {
+		return true
+	}
+	now := l.clock.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[symbol]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[symbol] = b
+	}
+	l.refill(b, now)
+
+	if b.tokens >= 1 {
+		b.tokens--
+		if b.held != nil {
+			// A newer trade got through first; the held one is stale
+			b.dropped++
+		}
+		b.held = nil
+		return true
+	}
+
+	if b.held != nil {
+		b.dropped++
+	}
+	b.held = msg
+	if b.timer == nil {
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		b.timer = l.clock.AfterFunc(wait, func() { l.release(symbol) })
+	}
+	return false
+}
+
+func (l *rateLimiter) refill(b *tokenBucket, now time.Time) {
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+}
+
+// release hands symbol's held trade, if any, to handle with the token it
+// was waiting for
+func (l *rateLimiter) release(symbol string) {
+	l.mu.Lock()
+	b := l.buckets[symbol]
+	b.timer = nil
+	msg := b.held
+	if msg != nil {
+		l.refill(b, l.clock.Now())
+		b.tokens--
+		b.held = nil
+	}
+	l.mu.Unlock()
+
+	if msg != nil {
+		l.handle(msg)
+	}
+}
+
+// drops returns the trades dropped so far by symbol, for /healthz
+func (l *rateLimiter) drops() map[string]uint64 {
+	if l == nil {
+		return map[string]uint64{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make(map[string]uint64, len(l.buckets))
+	for sym, b := range l.buckets {
+		if b.dropped > 0 {
+			out[sym] = b.dropped
+		}
+	}
+	return out
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// A trade arriving while a released one is being handled is held for the
+// next token, not counted as a drop, and the released trade doesn't go
+// back through allow
+func TestRateLimiterReleaseInterleaved(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+	l := newRateLimiter(10, clock)
+	for i := 0; i < 10; i++ {
+		if !l.allow("btcusdt", &nats.Msg{}) {
+			t.Fatalf("trade %d within the burst was held", i)
+		}
+	}
+
+	held, newer := &nats.Msg{Subject: "held"}, &nats.Msg{Subject: "newer"}
+	var handled []*nats.Msg
+	l.handle = func(msg *nats.Msg) {
+		if msg == held && l.allow("btcusdt", newer) {
+			t.Error("newer trade got a token while the bucket was empty")
+		}
+		handled = append(handled, msg)
+	}
+	if l.allow("btcusdt", held) {
+		t.Fatal("trade over the limit was let through")
+	}
+
+	// One token every 100ms releases each held trade in turn
+	for i, want := range []*nats.Msg{held, newer} {
+		clock.Advance(100 * time.Millisecond)
+		if len(handled) != i+1 || handled[i] != want {
+			t.Fatalf("after %dms handled %d trades, want the %s one", (i+1)*100, len(handled), want.Subject)
+		}
+	}
+	if drops := l.drops(); len(drops) != 0 {
+		t.Errorf("drops = %v, want none", drops)
+	}
+}
+
+// A trade replaced while held is dropped; the newest is the one released
+func TestRateLimiterReplacesHeld(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+	l := newRateLimiter(1, clock)
+	var handled []*nats.Msg
+	l.handle = func(msg *nats.Msg) { handled = append(handled, msg) }
+
+	if !l.allow("btcusdt", &nats.Msg{}) {
+		t.Fatal("first trade was held")
+	}
+	first, second := &nats.Msg{Subject: "first"}, &nats.Msg{Subject: "second"}
+	if l.allow("btcusdt", first) || l.allow("btcusdt", second) {
+		t.Fatal("trade over the limit was let through")
+	}
+
+	clock.Advance(time.Second)
+	if len(handled) != 1 || handled[0] != second {
+		t.Errorf("handled %d trades, want only the newest", len(handled))
+	}
+	if drops := l.drops(); drops["btcusdt"] != 1 {
+		t.Errorf("drops = %v, want 1 for btcusdt", drops)
+	}
+}
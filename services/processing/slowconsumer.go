@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+// slowConsumers remembers subscriptions NATS has flagged as slow consumers,
+// so /healthz can report how many messages each has dropped
+var slowConsumers = struct {
+	mu   sync.Mutex
+	subs map[*nats.Subscription]string
+}{subs: make(map[*nats.Subscription]string)}
+
+// natsErrHandler logs asynchronous NATS errors. Slow-consumer errors arrive
+// once per episode, not per dropped message; the drop count itself comes
+// from the subscription.
+func natsErrHandler(nc *nats.Conn, sub *nats.Subscription, err error) {
+	if sub == nil || !errors.Is(err, nats.ErrSlowConsumer) {
+		log.Printf("NATS error: %v", err)
+		return
+	}
+	slowConsumers.mu.Lock()
+	slowConsumers.subs[sub] = sub.Subject
+	slowConsumers.mu.Unlock()
+
+	dropped, _ := sub.Dropped()
+	log.Printf("Slow consumer on %s, dropping messages (%d dropped so far; processing is falling behind)", sub.Subject, dropped)
+}
+
+// slowConsumerDrops totals dropped messages by subject
+func slowConsumerDrops() map[string]int {
+	slowConsumers.mu.Lock()
+	defer slowConsumers.mu.Unlock()
+
+	drops := make(map[string]int, len(slowConsumers.subs))
+	for sub, subject := range slowConsumers.subs {
+		n, err := sub.Dropped()
+		if err != nil {
+			continue // closed; its count is gone with it
+		}
+		drops[subject] += n
+	}
+	return drops
+}
@@ -0,0 +1,25 @@
+package main
+
+import "math"
+
+// SpikeEvent is published on events.spike when a tick deviates sharply
+// from the moving average
+type SpikeEvent struct {
+	Symbol  string  `json:"symbol"`
+	Price   float64 `json:"price"`
+	Mean    float64 `json:"mean"`
+	StdDev  float64 `json:"std_dev"`
+	ZScore  float64 `json:"z_score"`
+	Time    int64   `json:"time"`
+	TraceID string  `json:"trace_id,omitempty"`
+}
+
+// detectSpike returns the z-score of price against the window and whether
+// it lies more than k standard deviations out. A flat window can't spike.
+func detectSpike(price, mean, stddev, k float64) (float64, bool) {
+	if stddev <= 0 {
+		return 0, false
+	}
+	z := (price - mean) / stddev
+	return z, math.Abs(z) > k
+}
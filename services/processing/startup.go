@@ -0,0 +1,43 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// consuming reports whether trades.raw has been subscribed yet, for /healthz
+var consuming atomic.Bool
+
+// waitForStart blocks until processing may consume trades: after delay
+// (STARTUP_DELAY) and, if waitReady is set (WAIT_FOR_READY), once a
+// control.ready message arrives. The api repeats control.ready while it
+// runs, and it can also be sent by hand.
+func waitForStart(nc *nats.Conn, delay time.Duration, waitReady bool) {
+	ready := make(chan struct{})
+	if waitReady {
+		var once sync.Once
+		// Subscribe before the delay so a signal sent meanwhile still counts
+		sub, err := nc.Subscribe("control.ready", func(msg *nats.Msg) {
+			once.Do(func() { close(ready) })
+		})
+		if err != nil {
+			log.Printf("control.ready subscribe failed, not waiting for it: %v", err)
+			close(ready)
+		} else {
+			defer sub.Unsubscribe()
+			log.Println("Waiting for control.ready before consuming trades")
+		}
+	} else {
+		close(ready)
+	}
+
+	if delay > 0 {
+		log.Printf("Waiting %s before consuming trades", delay)
+		time.Sleep(delay)
+	}
+	<-ready
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// tickSizes maps symbols to the price increment published prices are
+// rounded to, from the "tick_size" field of the API's COINS_FILE entries
+type tickSizes map[string]tickSize
+
+type tickSize struct {
+	size     float64
+	decimals int // decimals in size, to format away float noise after rounding
+}
+
+// loadTickSizes reads the coins with a tick size from path; other coin
+// fields are the API's business
+func loadTickSizes(path string) (tickSizes, error) {
+	ticks := make(tickSizes)
+	if path == "" {
+		return ticks, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var coins []struct {
+		Symbol   string  `json:"symbol"`
+		TickSize float64 `json:"tick_size"`
+	}
+	if err := json.Unmarshal(data, &coins); err != nil {
+		return nil, err
+	}
+	for _, c := range coins {
+		switch {
+		case c.TickSize == 0:
+		case c.TickSize < 0 || math.IsInf(c.TickSize, 0):
+			return nil, fmt.Errorf("coin %s: tick_size must be positive", c.Symbol)
+		default:
+			s := strconv.FormatFloat(c.TickSize, 'f', -1, 64)
+			decimals := 0
+			if i := strings.IndexByte(s, '.'); i >= 0 {
+				decimals = len(s) - i - 1
+			}
+			ticks[strings.ToLower(c.Symbol)] = tickSize{size: c.TickSize, decimals: decimals}
+		}
+	}
+	return ticks, nil
+}
+
+// round returns price rounded to symbol's tick size, and whether symbol
+// has one
+func (t tickSizes) round(symbol string, price float64) (float64, bool) {
+	tick, ok := t[symbol]
+	if !ok {
+		return price, false
+	}
+	rounded := math.Round(price/tick.size) * tick.size
+	// 0.1*3 is 0.30000000000000004; go through the decimal form to get 0.3
+	rounded, _ = strconv.ParseFloat(strconv.FormatFloat(rounded, 'f', tick.decimals, 64), 64)
+	return rounded, true
+}
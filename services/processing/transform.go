@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// priceTransform rescales incoming prices before any indicator sees them
+// (PRICE_SCALE, PRICE_OFFSET), e.g. for a fixed currency rate or fee
+type priceTransform struct {
+	Scale  float64 `json:"scale"`
+	Offset float64 `json:"offset"`
+}
+
+func parsePriceTransform(scale, offset string) (*priceTransform, error) {
+	t := &priceTransform{Scale: 1}
+	if scale != "" {
+		v, err := strconv.ParseFloat(scale, 64)
+		if err != nil || v <= 0 || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("invalid PRICE_SCALE %q (must be a positive number)", scale)
+		}
+		t.Scale = v
+	}
+	if offset != "" {
+		v, err := strconv.ParseFloat(offset, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("invalid PRICE_OFFSET %q", offset)
+		}
+		t.Offset = v
+	}
+	if t.Scale == 1 && t.Offset == 0 {
+		return nil, nil
+	}
+	return t, nil
+}
+
+// apply returns the transformed price; a nil transform leaves it unchanged
+func (t *priceTransform) apply(p float64) float64 {
+	if t == nil {
+		return p
+	}
+	return p*t.Scale + t.Offset
+}
@@ -0,0 +1,69 @@
+package main
+
+import "sync"
+
+// vwcTracker keeps a volume-adjusted price change over the last N tick to
+// tick moves: each move is scaled by its trade's quantity relative to the
+// window's average quantity, then summed. With even volume it equals the
+// plain price change over the window; moves on heavy trades count for more
+// and moves on thin ones for less.
+type vwcTracker struct {
+	mu sync.Mutex
+
+	moves []float64 // price change into each trade
+	qtys  []float64
+	next  int
+	count int
+
+	weighted float64 // running sum of move*qty
+	volume   float64 // running sum of qty
+
+	prev    float64
+	hasPrev bool
+}
+
+func newVWCTracker(window int) *vwcTracker {
+	return &vwcTracker{moves: make([]float64, window), qtys: make([]float64, window)}
+}
+
+// add records a trade. Trades without a quantity still move the price
+// reference but carry no weight.
+func (v *vwcTracker) add(price, qty float64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if !v.hasPrev {
+		v.prev, v.hasPrev = price, true
+		return
+	}
+	move := price - v.prev
+	v.prev = price
+
+	v.weighted += move*qty - v.moves[v.next]*v.qtys[v.next]
+	v.volume += qty - v.qtys[v.next]
+	v.moves[v.next], v.qtys[v.next] = move, qty
+	v.next = (v.next + 1) % len(v.moves)
+	if v.count < len(v.moves) {
+		v.count++
+	}
+}
+
+// value returns the volume-adjusted change, or false until the window is
+// full or while it holds no volume (e.g. kline streams)
+func (v *vwcTracker) value() (float64, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.count < len(v.moves) || v.volume <= 0 {
+		return 0, false
+	}
+	return v.weighted / v.volume * float64(v.count), true
+}
+
+func (v *vwcTracker) reset() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	clear(v.moves)
+	clear(v.qtys)
+	v.next, v.count, v.weighted, v.volume, v.hasPrev = 0, 0, 0, 0, false
+}
@@ -0,0 +1,19 @@
+package main
+
+// warmed reports whether p's moving-average window is full, so its
+// indicators describe a whole window rather than the first few trades
+func warmed(p processor) bool {
+	return p.SampleCount() >= p.Window()
+}
+
+// warmupGate decides whether a processed trade is published. With
+// SUPPRESS_UNTIL_WARM set it holds trades back until the window is full,
+// and again after every reset; otherwise every trade goes out, flagged
+// warmed:false until then.
+type warmupGate struct {
+	suppress bool
+}
+
+func (g warmupGate) publish(p ProcessedMessage) bool {
+	return !g.suppress || p.Warmed
+}
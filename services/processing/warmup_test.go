@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestWarmupGate(t *testing.T) {
+	procs := map[string]processor{"go": newGoProcessor()}
+	if native := newNativeProcessor(); native != nil {
+		procs["native"] = native
+	}
+
+	for name, proc := range procs {
+		t.Run(name, func(t *testing.T) {
+			proc.Reset()
+			defer proc.Reset()
+			window := proc.Window()
+
+			// feed adds n trades and returns how many each gate published,
+			// checking the warmed flag as it goes
+			feed := func(n, start int) (suppressed, flagged int) {
+				t.Helper()
+				for i := 1; i <= n; i++ {
+					proc.AddPrice(100 + float64(i))
+					p := ProcessedMessage{Symbol: "btcusdt", Price: 100 + float64(i), Warmed: warmed(proc)}
+					if want := start+i >= window; p.Warmed != want {
+						t.Fatalf("trade %d: warmed = %v, want %v", start+i, p.Warmed, want)
+					}
+					if (warmupGate{suppress: true}).publish(p) {
+						suppressed++
+					}
+					if (warmupGate{}).publish(p) {
+						flagged++
+					}
+				}
+				return suppressed, flagged
+			}
+
+			// Nothing goes out while the window fills...
+			if s, f := feed(window-1, 0); s != 0 || f != window-1 {
+				t.Fatalf("before warm: published %d suppressed, %d flagged; want 0, %d", s, f, window-1)
+			}
+			// ...and everything from the trade that fills it
+			if s, f := feed(window+5, window-1); s != window+5 || f != window+5 {
+				t.Fatalf("once warm: published %d suppressed, %d flagged; want %d each", s, f, window+5)
+			}
+
+			// A reset starts the warmup over
+			proc.Reset()
+			if s, _ := feed(window-1, 0); s != 0 {
+				t.Fatalf("after reset: published %d, want 0", s)
+			}
+			if s, _ := feed(1, window-1); s != 1 {
+				t.Fatal("not published once warm again")
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+// Package buildinfo reports a binary's version for /version endpoints.
+package buildinfo
+
+import "runtime/debug"
+
+// Info describes a build from the metadata injected with -ldflags, falling
+// back to the VCS stamp Go embeds in local builds
+func Info(version, commit, buildTime string) map[string]string {
+	info := map[string]string{
+		"version":    version,
+		"commit":     commit,
+		"build_time": buildTime,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && commit == "unknown":
+				info["commit"] = s.Value
+			case s.Key == "vcs.time" && buildTime == "unknown":
+				info["build_time"] = s.Value
+			}
+		}
+		info["go"] = bi.GoVersion
+	}
+	return info
+}
@@ -0,0 +1,68 @@
+// Package indexdef parses INDEXES, the synthetic symbols priced from a
+// basket of real ones.
+package indexdef
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Def is a synthetic symbol priced from a weighted basket of real symbols
+type Def struct {
+	Symbol       string
+	Constituents []string // in config order
+	Weights      map[string]float64
+}
+
+// Set holds the configured indexes by symbol
+type Set map[string]*Def
+
+// Parse reads semicolon-separated definitions such as
+// "top3=btcusdt:1,ethusdt:1,solusdt:1". Weights are relative and default
+// to 1.
+func Parse(v string) (Set, error) {
+	set := make(Set)
+	if v == "" {
+		return set, nil
+	}
+	for _, def := range strings.Split(v, ";") {
+		name, basket, ok := strings.Cut(def, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok || name == "" || strings.TrimSpace(basket) == "" {
+			return nil, fmt.Errorf("bad index %q (want name=symbol:weight,...)", def)
+		}
+		idx := &Def{Symbol: name, Weights: make(map[string]float64)}
+		for _, part := range strings.Split(basket, ",") {
+			sym, w, hasWeight := strings.Cut(part, ":")
+			sym = strings.ToLower(strings.TrimSpace(sym))
+			weight := 1.0
+			if hasWeight {
+				var err error
+				weight, err = strconv.ParseFloat(strings.TrimSpace(w), 64)
+				if err != nil || weight <= 0 {
+					return nil, fmt.Errorf("index %s: bad weight %q for %s", name, w, sym)
+				}
+			}
+			if sym == "" || sym == name {
+				return nil, fmt.Errorf("index %s: bad constituent %q", name, part)
+			}
+			if _, dup := idx.Weights[sym]; dup {
+				return nil, fmt.Errorf("index %s: %s listed twice", name, sym)
+			}
+			idx.Constituents = append(idx.Constituents, sym)
+			idx.Weights[sym] = weight
+		}
+		set[name] = idx
+	}
+	return set, nil
+}
+
+// SymbolsFor returns the symbols to stream for sym: an index's
+// constituents, or sym itself
+func (set Set) SymbolsFor(sym string) []string {
+	if idx, ok := set[sym]; ok {
+		return idx.Constituents
+	}
+	return []string{sym}
+}
@@ -0,0 +1,93 @@
+// Package msgcodec encodes and decodes the trade messages the services
+// exchange on NATS, as JSON or MessagePack (MSG_FORMAT).
+package msgcodec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Content types for trade messages on NATS. Non-JSON messages carry theirs
+// in a Content-Type header, so consumers decode whatever they receive
+// regardless of their own MSG_FORMAT; untagged messages are JSON.
+const (
+	ContentTypeJSON    = "application/json"
+	ContentTypeMsgpack = "application/msgpack"
+)
+
+// Codec encodes and decodes TradeMessage/ProcessedMessage on NATS
+type Codec interface {
+	ContentType() string
+	Append(b []byte, v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// Appender is implemented by hot-path messages with a hand-written JSON
+// encoder, avoiding json.Marshal's reflection and per-call allocation
+type Appender interface {
+	AppendJSON(b []byte) []byte
+}
+
+// Parse returns the codec for MSG_FORMAT
+func Parse(v string) (Codec, error) {
+	switch v {
+	case "", "json":
+		return JSON{}, nil
+	case "msgpack":
+		return Msgpack{}, nil
+	}
+	return nil, fmt.Errorf("unknown MSG_FORMAT %q (json or msgpack)", v)
+}
+
+// Decode decodes msg with the codec named in its Content-Type header
+func Decode(msg *nats.Msg, v any) error {
+	if msg.Header.Get("Content-Type") == ContentTypeMsgpack {
+		return Msgpack{}.Unmarshal(msg.Data, v)
+	}
+	return json.Unmarshal(msg.Data, v)
+}
+
+// JSON is the default codec, and what untagged messages are decoded with
+type JSON struct{}
+
+func (JSON) ContentType() string { return ContentTypeJSON }
+
+// Append uses the hand-written encoder when v has one
+func (JSON) Append(b []byte, v any) ([]byte, error) {
+	if a, ok := v.(Appender); ok {
+		return a.AppendJSON(b), nil
+	}
+	data, err := json.Marshal(v)
+	return append(b, data...), err
+}
+
+func (JSON) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// Msgpack reads the json struct tags, so field names and omitempty match
+// the JSON encoding
+type Msgpack struct{}
+
+func (Msgpack) ContentType() string { return ContentTypeMsgpack }
+
+func (Msgpack) Append(b []byte, v any) ([]byte, error) {
+	buf := bytes.NewBuffer(b)
+	enc := msgpack.GetEncoder()
+	defer msgpack.PutEncoder(enc)
+	enc.Reset(buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	err := enc.Encode(v)
+	return buf.Bytes(), err
+}
+
+func (Msgpack) Unmarshal(data []byte, v any) error {
+	dec := msgpack.GetDecoder()
+	defer msgpack.PutDecoder(dec)
+	dec.Reset(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
@@ -0,0 +1,31 @@
+package msgcodec
+
+import (
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+type trade struct {
+	Symbol string  `json:"symbol"`
+	Price  float64 `json:"price"`
+}
+
+// Decode goes by the message's Content-Type, not the consumer's MSG_FORMAT
+func TestDecode(t *testing.T) {
+	want := trade{Symbol: "btcusdt", Price: 42000.12}
+	for _, codec := range []Codec{JSON{}, Msgpack{}} {
+		data, err := codec.Append(nil, want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := &nats.Msg{Data: data}
+		if codec.ContentType() != ContentTypeJSON {
+			msg.Header = nats.Header{"Content-Type": []string{codec.ContentType()}}
+		}
+		var got trade
+		if err := Decode(msg, &got); err != nil || got != want {
+			t.Errorf("%s: got %+v, %v", codec.ContentType(), got, err)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+// Package natsconn holds the NATS connection settings the services and
+// tools share.
+package natsconn
+
+import (
+	"log"
+	"os"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Options builds connection options from NATS_CREDS, NATS_USER/NATS_PASSWORD
+// and NATS_TLS so a service can join a secured cluster
+func Options() []nats.Option {
+	var opts []nats.Option
+	if creds := os.Getenv("NATS_CREDS"); creds != "" {
+		opts = append(opts, nats.UserCredentials(creds))
+	}
+	if user := os.Getenv("NATS_USER"); user != "" {
+		opts = append(opts, nats.UserInfo(user, os.Getenv("NATS_PASSWORD")))
+	}
+	switch os.Getenv("NATS_TLS") {
+	case "", "false":
+	case "true":
+		opts = append(opts, nats.Secure())
+	default:
+		log.Fatalf("Invalid NATS_TLS %q (want true or false)", os.Getenv("NATS_TLS"))
+	}
+	return opts
+}
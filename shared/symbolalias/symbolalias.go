@@ -0,0 +1,46 @@
+// Package symbolalias maps other spellings of a symbol (SYMBOL_ALIASES)
+// to the canonical one.
+package symbolalias
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Aliases maps other spellings of a symbol, e.g. XBTUSD or btc-usd, to the
+// canonical lowercase form such as btcusdt
+type Aliases map[string]string
+
+// Parse reads comma-separated alias=symbol pairs, such as
+// "xbtusd=btcusdt,btc-usd=btcusdt". Matching ignores case.
+func Parse(v string) (Aliases, error) {
+	aliases := make(Aliases)
+	if v == "" {
+		return aliases, nil
+	}
+	for _, pair := range strings.Split(v, ",") {
+		alias, symbol, ok := strings.Cut(pair, "=")
+		alias = strings.ToLower(strings.TrimSpace(alias))
+		symbol = strings.ToLower(strings.TrimSpace(symbol))
+		if !ok || alias == "" || symbol == "" {
+			return nil, fmt.Errorf("bad alias %q (want alias=symbol)", pair)
+		}
+		aliases[alias] = symbol
+	}
+	return aliases, nil
+}
+
+// Normalize returns the canonical form of s: lowercased, then looked up as
+// an alias as written and again without -, / or _ separators. Anything not
+// aliased comes back lowercased, for the caller to validate.
+func (a Aliases) Normalize(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if symbol, ok := a[s]; ok {
+		return symbol
+	}
+	bare := strings.NewReplacer("-", "", "/", "", "_", "").Replace(s)
+	if symbol, ok := a[bare]; ok {
+		return symbol
+	}
+	return bare
+}
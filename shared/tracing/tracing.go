@@ -0,0 +1,96 @@
+// Package tracing sets up OpenTelemetry and carries trace context across
+// NATS in message headers.
+package tracing
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer is the pipeline's tracer, shared by every service
+var Tracer = otel.Tracer("trading-pipeline")
+
+// Init exports spans over OTLP/HTTP when OTEL_EXPORTER_OTLP_ENDPOINT is
+// set. Otherwise the global no-op provider stays in place and spans cost
+// next to nothing. The returned func flushes pending spans on shutdown.
+func Init(ctx context.Context, service string) func(context.Context) error {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		log.Fatalf("Failed to create OTLP exporter: %v", err)
+	}
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the default name
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", service)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		log.Printf("OTel resource error: %v", err)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	log.Printf("Tracing enabled, exporting to %s", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"))
+	return tp.Shutdown
+}
+
+// headerCarrier lets the propagator read and write NATS message headers
+type headerCarrier nats.Header
+
+func (c headerCarrier) Get(key string) string {
+	if v := c[key]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c headerCarrier) Set(key, value string) { c[key] = []string{value} }
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// ContextFromMsg continues the trace carried in a message's headers
+func ContextFromMsg(msg *nats.Msg) context.Context {
+	if msg.Header == nil {
+		return context.Background()
+	}
+	return otel.GetTextMapPropagator().Extract(context.Background(), headerCarrier(msg.Header))
+}
+
+// Publish publishes data with the span in ctx injected into the headers,
+// or as a plain publish when there's nothing to propagate
+func Publish(ctx context.Context, nc *nats.Conn, subject string, data []byte) error {
+	return PublishMsg(ctx, nc, &nats.Msg{Subject: subject, Data: data})
+}
+
+// PublishMsg is Publish for a message that may already have headers
+func PublishMsg(ctx context.Context, nc *nats.Conn, msg *nats.Msg) error {
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		if msg.Header == nil {
+			msg.Header = nats.Header{}
+		}
+		otel.GetTextMapPropagator().Inject(ctx, headerCarrier(msg.Header))
+	}
+	return nc.PublishMsg(msg)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultServerURL = "http://localhost:8080"
+
+// apiClient is the TUI's only path to the API service, so tests can point
+// it at an httptest.Server and swap in their own *http.Client
+type apiClient struct {
+	baseURL string
+	http    *http.Client
+}
+
+func newAPIClient(baseURL string, hc *http.Client) *apiClient {
+	if hc == nil {
+		hc = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &apiClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    hc,
+	}
+}
+
+// Error codes the API sends with errors that callers branch on; see
+// APIError.Code
+const (
+	codeUnknownSymbol = "unknown_symbol"
+	codeDBUnavailable = "db_unavailable"
+	codeNoData        = "no_data" // nothing received for the symbol yet
+)
+
+// APIError is an error response from the API. Failures to reach it at all
+// (refused connections, timeouts) come back as the *http.Client's errors
+// instead, so errors.As(err, &apiErr) tells the two apart.
+type APIError struct {
+	Status  int    // HTTP status
+	Code    string // machine-readable cause, e.g. codeUnknownSymbol
+	Message string // the API's human-readable message
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s (%d %s)", e.Message, e.Status, e.Code)
+}
+
+// getJSON GETs path and decodes the response into v
+func (c *apiClient) getJSON(path string, v any) error {
+	resp, err := c.http.Get(c.baseURL + path)
+	if err != nil {
+		return err
+	}
+	return decodeResponse(resp, v)
+}
+
+// postJSON POSTs body as JSON to path and decodes the response into v,
+// which may be nil
+func (c *apiClient) postJSON(path string, body, v any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Post(c.baseURL+path, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	return decodeResponse(resp, v)
+}
+
+// decodeResponse decodes a 2xx body into v, and turns anything else into
+// an *APIError. Servers from before error codes send none, and non-JSON
+// errors (a proxy's HTML page) have no message either; both fall back to
+// what the status says.
+func decodeResponse(resp *http.Response, v any) error {
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if v == nil {
+			return nil
+		}
+		return json.NewDecoder(resp.Body).Decode(v)
+	}
+
+	var body struct {
+		Error string `json:"error"`
+		Code  string `json:"code"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+	apiErr := &APIError{Status: resp.StatusCode, Code: body.Code, Message: body.Error}
+	if apiErr.Code == "" {
+		apiErr.Code = statusErrorCode(resp.StatusCode)
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
+
+// statusErrorCode is the code the API gives errors with no more specific
+// one
+func statusErrorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusMethodNotAllowed:
+		return "method_not_allowed"
+	case http.StatusBadGateway:
+		return "upstream_error"
+	case http.StatusServiceUnavailable:
+		return "unavailable"
+	}
+	return "internal"
+}
+
+// hasCode reports whether err is an *APIError with code
+func hasCode(err error, code string) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+// unreachable reports whether err means the API couldn't be reached at
+// all, as opposed to answering with an error
+func unreachable(err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// errorMessage is what the TUI shows for err: specific wording for the
+// causes a user can act on, the API's own message otherwise, and
+// errServerDown when the API couldn't be reached
+func errorMessage(err error) string {
+	var (
+		apiErr *APIError
+		urlErr *url.Error
+	)
+	switch {
+	case errors.As(err, &urlErr):
+		return errServerDown
+	case !errors.As(err, &apiErr):
+		return fmt.Sprintf("Unexpected response: %v", err)
+	}
+	switch apiErr.Code {
+	case codeUnknownSymbol:
+		return "Unknown symbol: the API doesn't list it in /api/coins"
+	case codeDBUnavailable:
+		return "Database not available: the API is running without TimescaleDB"
+	case codeNoData:
+		return "No data for this symbol yet"
+	}
+	return fmt.Sprintf("Server error: %s", apiErr.Message)
+}
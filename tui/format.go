@@ -0,0 +1,117 @@
+package main
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// quoteSymbols maps quote assets to a display prefix. Quotes not listed are
+// shown as a suffix, e.g. "0.05123 BTC" would otherwise read as dollars.
+var quoteSymbols = map[string]string{
+	"usdt":  "$",
+	"usdc":  "$",
+	"busd":  "$",
+	"fdusd": "$",
+	"usd":   "$",
+	"eur":   "€",
+	"gbp":   "£",
+}
+
+// numberLocale holds the separators used when rendering numbers
+type numberLocale struct {
+	decimal  string
+	thousand string
+}
+
+// locale is detected once from the environment at startup
+var locale = detectLocale()
+
+// detectLocale picks separators from LC_ALL/LC_NUMERIC/LANG, defaulting to
+// the "1,234.56" style
+func detectLocale() numberLocale {
+	lang := ""
+	for _, key := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+		if v := os.Getenv(key); v != "" {
+			lang = strings.ToLower(v)
+			break
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(lang, "fr"), strings.HasPrefix(lang, "ru"), strings.HasPrefix(lang, "pl"),
+		strings.HasPrefix(lang, "cs"), strings.HasPrefix(lang, "sv"), strings.HasPrefix(lang, "fi"):
+		return numberLocale{decimal: ",", thousand: " "}
+	case strings.HasPrefix(lang, "de"), strings.HasPrefix(lang, "es"), strings.HasPrefix(lang, "it"),
+		strings.HasPrefix(lang, "nl"), strings.HasPrefix(lang, "pt"), strings.HasPrefix(lang, "tr"):
+		return numberLocale{decimal: ",", thousand: "."}
+	default:
+		return numberLocale{decimal: ".", thousand: ","}
+	}
+}
+
+// pricePrecision returns how many decimals to show so that prices keep
+// roughly six significant digits: 2 for BTC, 6 for DOGE, more for sub-cent coins
+func pricePrecision(price float64) int {
+	abs := math.Abs(price)
+	if abs == 0 || math.IsNaN(abs) || math.IsInf(abs, 0) {
+		return 2
+	}
+
+	digits := int(math.Floor(math.Log10(abs))) + 1
+	decimals := 6 - digits
+	if decimals < 2 {
+		decimals = 2
+	}
+	if decimals > 10 {
+		decimals = 10
+	}
+	return decimals
+}
+
+// displayPrecision prefers the coin's configured precision, falling back
+// to one derived from the price
+func displayPrecision(price float64, coinPrecision int) int {
+	if coinPrecision > 0 {
+		return coinPrecision
+	}
+	return pricePrecision(price)
+}
+
+// formatNumber renders v with the given decimals and locale separators
+func formatNumber(v float64, decimals int) string {
+	s := strconv.FormatFloat(math.Abs(v), 'f', decimals, 64)
+	intPart, fracPart, _ := strings.Cut(s, ".")
+
+	// Group the integer part in threes
+	var b strings.Builder
+	if v < 0 && strings.Trim(s, "0.") != "" {
+		b.WriteByte('-')
+	}
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(locale.thousand)
+		}
+		b.WriteRune(r)
+	}
+	if fracPart != "" {
+		b.WriteString(locale.decimal)
+		b.WriteString(fracPart)
+	}
+	return b.String()
+}
+
+// formatAmount renders v with a fixed precision in the quote currency
+func formatAmount(v float64, decimals int, quote string) string {
+	num := formatNumber(v, decimals)
+
+	quote = strings.ToLower(quote)
+	if quote == "" {
+		return "$" + num
+	}
+	if sym, ok := quoteSymbols[quote]; ok {
+		return sym + num
+	}
+	return num + " " + strings.ToUpper(quote)
+}
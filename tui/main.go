@@ -1,84 +1,55 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
-)
-
-const serverURL = "http://localhost:8080"
-
-// Styles
-var (
-	boxStyle = lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("10")).
-			Padding(1, 2)
-
-	priceStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("15"))
-
-	upStyle = lipgloss.NewStyle().
-		Foreground(lipgloss.Color("10"))
-
-	downStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("9"))
-
-	labelStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("8"))
-
-	valueStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("15"))
-
-	headerStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("10")).
-			MarginBottom(1)
-
-	helpStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("8")).
-			MarginTop(1)
-
-	errorStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("9"))
-
-	selectedStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("10")).
-			Bold(true)
-
-	itemStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("8"))
-
-	timeStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("6"))
 )
 
 // API response types
 type PriceResponse struct {
-	Price float64 `json:"price"`
+	Price   float64  `json:"price"`
+	Quality *float64 `json:"quality"` // nil from servers that don't score data quality
 }
 
 type StatsResponse struct {
 	MovingAverage float64 `json:"moving_average"`
 	High          float64 `json:"high"`
 	Low           float64 `json:"low"`
+	RollingHigh   float64 `json:"rolling_high"`
+	RollingLow    float64 `json:"rolling_low"`
+}
+
+type BookResponse struct {
+	Bid    float64 `json:"bid"`
+	Ask    float64 `json:"ask"`
+	Spread float64 `json:"spread"`
 }
 
 type SymbolResponse struct {
-	Symbol string `json:"symbol"`
-	Name   string `json:"name"`
+	Symbol    string `json:"symbol"`
+	Name      string `json:"name"`
+	Quote     string `json:"quote"`
+	Precision int    `json:"precision"`
 }
 
 type CoinInfo struct {
 	Symbol string `json:"symbol"`
 	Name   string `json:"name"`
+	Quote  string `json:"quote"`
+}
+
+type RecentResponse struct {
+	Symbol string `json:"symbol"`
+	Prices []struct {
+		Price float64 `json:"price"`
+		Time  int64   `json:"time"` // unix ms
+	} `json:"prices"`
 }
 
 type HistoryTrade struct {
@@ -91,15 +62,29 @@ type HistoryTrade struct {
 type DashboardData struct {
 	Symbol        string
 	CoinName      string
+	Quote         string
+	Precision     int // display decimals from the coin config; 0 = by magnitude
 	Price         float64
 	PrevPrice     float64
 	High          float64
 	Low           float64
+	RollingHigh   float64
+	RollingLow    float64
 	MovingAverage float64
 	Change        float64
 	ChangePercent float64
+	BookSpread    float64
+	HasBook       bool
+	Quality       float64 // 0-1, how far the price can be trusted
+	HasQuality    bool
 	Connected     bool
 	Error         string
+
+	// Pieces that failed to fetch this time, though the server answered.
+	// keepStale fills them with the last values fetched.
+	SymbolStale bool
+	PriceStale  bool
+	StatsStale  bool
 }
 
 // View modes
@@ -111,87 +96,299 @@ const (
 	historyView
 )
 
+// Polling backs off up to this delay while the server is unreachable
+const maxReconnectDelay = 30 * time.Second
+
+// Refresh interval bounds and the steps +/- move between
+const (
+	minInterval = 100 * time.Millisecond
+	maxInterval = 5 * time.Second
+)
+
+var intervalSteps = []time.Duration{
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+}
+
 // Messages
-type tickMsg time.Time
+type tickMsg struct {
+	gen int // ticks from an older generation are dropped
+}
 type dataMsg DashboardData
 type coinsMsg []CoinInfo
-type symbolChangedMsg struct{}
-type historyMsg []HistoryTrade
+type symbolChangedMsg struct {
+	err error
+}
+type historyMsg struct {
+	trades []HistoryTrade
+	err    error
+}
+type recentMsg RecentResponse
 
 // Model
 type model struct {
-	mode         viewMode
-	data         DashboardData
-	history      []float64
-	dbHistory    []HistoryTrade
-	quitting     bool
-	coins        []CoinInfo
-	coinCursor   int
-	switching    bool
+	mode          viewMode
+	data          DashboardData
+	history       []float64
+	dbHistory     []HistoryTrade // nil until loaded
+	historyErr    string         // why the last history fetch failed
+	quitting      bool
+	coins         []CoinInfo
+	coinCursor    int
+	coinErr       string // why the last switch failed
+	switching     bool
 	historyScroll int
+	interval      time.Duration
+	tickGen       int
+	paused        bool
+	smooth        bool   // draw the sparkline from an EMA of the history
+	failures      int    // consecutive failed fetches; >0 means reconnecting
+	lastError     string // reason for the most recent failure
+	api           *apiClient
 }
 
-func initialModel() model {
+func initialModel(api *apiClient, interval time.Duration) model {
 	return model{
-		mode:    coinSelectView, // Start with coin selection
-		history: make([]float64, 0, 20),
+		mode:     coinSelectView, // Start with coin selection
+		history:  make([]float64, 0, maxHistory),
+		interval: interval,
+		api:      api,
 	}
 }
 
 func (m model) Init() tea.Cmd {
-	return fetchCoins() // Fetch coins first
+	return fetchCoins(m.api) // Fetch coins first
 }
 
-func tick() tea.Cmd {
-	return tea.Tick(500*time.Millisecond, func(t time.Time) tea.Msg {
-		return tickMsg(t)
+func tick(d time.Duration, gen int) tea.Cmd {
+	return tea.Tick(d, func(time.Time) tea.Msg {
+		return tickMsg{gen: gen}
 	})
 }
 
-func fetchData() tea.Cmd {
+// maxHistory is how many prices the sparkline keeps
+const maxHistory = 20
+
+// displayLoc is the zone times are shown in (-timezone, default local)
+var displayLoc = time.Local
+
+// applyChange fills next's change fields relative to prev. Change is only
+// meaningful between two live prices for the same symbol.
+func applyChange(prev, next DashboardData) DashboardData {
+	if prev.Price > 0 && next.Price > 0 && prev.Symbol == next.Symbol {
+		next.Change = next.Price - prev.Price
+		next.ChangePercent = (next.Change / prev.Price) * 100
+	}
+	next.PrevPrice = prev.Price
+	return next
+}
+
+// keepStale fills the pieces of next that failed to fetch with prev's
+// values, which stay marked stale. Price and stats only carry over while
+// the symbol is the same.
+func keepStale(prev, next DashboardData) DashboardData {
+	if next.SymbolStale {
+		next.Symbol = prev.Symbol
+		next.CoinName = prev.CoinName
+		next.Quote = prev.Quote
+		next.Precision = prev.Precision
+	}
+	if prev.Symbol != next.Symbol {
+		return next
+	}
+	if next.PriceStale {
+		next.Price = prev.Price
+		next.Quality = prev.Quality
+		next.HasQuality = prev.HasQuality
+	}
+	if next.StatsStale {
+		next.MovingAverage = prev.MovingAverage
+		next.High = prev.High
+		next.Low = prev.Low
+		next.RollingHigh = prev.RollingHigh
+		next.RollingLow = prev.RollingLow
+	}
+	return next
+}
+
+// appendHistory adds a live price to the sparkline series, keeping at most max
+func appendHistory(history []float64, price float64, max int) []float64 {
+	if price <= 0 {
+		return history
+	}
+	history = append(history, price)
+	if len(history) > max {
+		history = history[len(history)-max:]
+	}
+	return history
+}
+
+// seedMaxAge leaves recent prices older than this out of the seeded
+// sparkline; the API keeps a symbol's ring from whenever it last traded
+const seedMaxAge = 5 * time.Minute
+
+// seedHistory fills a sparkline series that has at most its first live
+// price from /api/recent, if recent is for the symbol being shown (current
+// is "" before the first fetch). That first live price is usually the
+// newest recent one, so it's only kept if it differs.
+func seedHistory(history []float64, current string, recent RecentResponse) []float64 {
+	if len(history) > 1 || recent.Symbol == "" || (current != "" && recent.Symbol != current) {
+		return history
+	}
+	cutoff := time.Now().Add(-seedMaxAge).UnixMilli()
+	seeded := make([]float64, 0, maxHistory)
+	for _, p := range recent.Prices {
+		if p.Time >= cutoff {
+			seeded = appendHistory(seeded, p.Price, maxHistory)
+		}
+	}
+	if len(seeded) == 0 {
+		return history
+	}
+	if len(history) == 1 && history[0] != seeded[len(seeded)-1] {
+		seeded = appendHistory(seeded, history[0], maxHistory)
+	}
+	return seeded
+}
+
+// sparkAlpha is the EMA weight of the newest price when smoothing the
+// sparkline; lower is smoother but lags more
+const sparkAlpha = 0.3
+
+// smoothEMA returns the exponential moving average of series, seeded with
+// its first value
+func smoothEMA(series []float64, alpha float64) []float64 {
+	out := make([]float64, len(series))
+	for i, v := range series {
+		if i == 0 {
+			out[i] = v
+			continue
+		}
+		out[i] = alpha*v + (1-alpha)*out[i-1]
+	}
+	return out
+}
+
+// restartTick starts a fresh tick loop, orphaning any tick already in flight
+func (m *model) restartTick() tea.Cmd {
+	m.tickGen++
+	return tick(m.pollDelay(), m.tickGen)
+}
+
+// pollDelay is the refresh interval, or a growing backoff while reconnecting
+func (m model) pollDelay() time.Duration {
+	if m.failures == 0 {
+		return m.interval
+	}
+	shift := m.failures - 1
+	if shift > 5 {
+		shift = 5
+	}
+	delay := time.Second << shift
+	if delay > maxReconnectDelay {
+		delay = maxReconnectDelay
+	}
+	return delay
+}
+
+// stepInterval moves the refresh interval one step faster (dir < 0) or slower
+func stepInterval(cur time.Duration, dir int) time.Duration {
+	if dir > 0 {
+		for _, d := range intervalSteps {
+			if d > cur {
+				return d
+			}
+		}
+		return maxInterval
+	}
+	for i := len(intervalSteps) - 1; i >= 0; i-- {
+		if intervalSteps[i] < cur {
+			return intervalSteps[i]
+		}
+	}
+	return minInterval
+}
+
+// Failure reasons the dashboard tells apart: never reached the server, or
+// lost a server it was showing (usually a restart or shutdown)
+const (
+	errServerDown    = "Server not running. Start with 'make run'"
+	errServerStopped = "Server stopped"
+)
+
+// fetchData fetches the symbol, price and stats each on their own, so one
+// the API lacks (an older or newer version) or can't serve right now only
+// goes stale. Only an unreachable server, or failures across the board,
+// make the whole fetch an error.
+func fetchData(c *apiClient) tea.Cmd {
 	return func() tea.Msg {
 		data := DashboardData{}
-
-		// Fetch symbol info
-		symbolResp, err := http.Get(serverURL + "/api/symbol")
-		if err != nil {
-			data.Error = "Server not running. Start with 'make run'"
-			return dataMsg(data)
+		var lastErr error
+		failed := func(err error) bool {
+			if err != nil {
+				lastErr = err
+			}
+			return err != nil
 		}
-		defer symbolResp.Body.Close()
 
+		// Fetch symbol info
 		var symbolData SymbolResponse
-		if err := json.NewDecoder(symbolResp.Body).Decode(&symbolData); err == nil {
+		if err := c.getJSON("/api/symbol", &symbolData); unreachable(err) {
+			data.Error = errorMessage(err)
+			return dataMsg(data)
+		} else if failed(err) {
+			data.SymbolStale = true
+		} else {
 			data.Symbol = symbolData.Symbol
 			data.CoinName = symbolData.Name
+			data.Quote = symbolData.Quote
+			data.Precision = symbolData.Precision
 		}
 
-		// Fetch price
-		priceResp, err := http.Get(serverURL + "/api/price")
-		if err != nil {
-			data.Error = "Failed to fetch price"
-			return dataMsg(data)
-		}
-		defer priceResp.Body.Close()
-
+		// Fetch price; there's none before the symbol's first trade
 		var priceData PriceResponse
-		if err := json.NewDecoder(priceResp.Body).Decode(&priceData); err == nil {
+		if err := c.getJSON("/api/price", &priceData); unreachable(err) {
+			data.Error = errorMessage(err)
+			return dataMsg(data)
+		} else if !hasCode(err, codeNoData) && failed(err) {
+			data.PriceStale = true
+		} else {
 			data.Price = priceData.Price
+			if priceData.Quality != nil {
+				data.Quality = *priceData.Quality
+				data.HasQuality = true
+			}
 		}
 
 		// Fetch stats
-		statsResp, err := http.Get(serverURL + "/api/stats")
-		if err != nil {
-			data.Error = "Failed to fetch stats"
-			return dataMsg(data)
-		}
-		defer statsResp.Body.Close()
-
 		var statsData StatsResponse
-		if err := json.NewDecoder(statsResp.Body).Decode(&statsData); err == nil {
+		if err := c.getJSON("/api/stats", &statsData); unreachable(err) {
+			data.Error = errorMessage(err)
+			return dataMsg(data)
+		} else if !hasCode(err, codeNoData) && failed(err) {
+			data.StatsStale = true
+		} else {
 			data.MovingAverage = statsData.MovingAverage
 			data.High = statsData.High
 			data.Low = statsData.Low
+			data.RollingHigh = statsData.RollingHigh
+			data.RollingLow = statsData.RollingLow
+		}
+
+		if data.SymbolStale && data.PriceStale && data.StatsStale {
+			data.Error = errorMessage(lastErr)
+			return dataMsg(data)
+		}
+
+		// Fetch best bid/ask (only available when ingestion tracks the book)
+		var bookData BookResponse
+		if c.getJSON("/api/book", &bookData) == nil {
+			data.BookSpread = bookData.Spread
+			data.HasBook = true
 		}
 
 		data.Connected = true
@@ -199,43 +396,39 @@ func fetchData() tea.Cmd {
 	}
 }
 
-func fetchCoins() tea.Cmd {
+func fetchCoins(c *apiClient) tea.Cmd {
 	return func() tea.Msg {
-		resp, err := http.Get(serverURL + "/api/coins")
-		if err != nil {
-			return coinsMsg(nil)
-		}
-		defer resp.Body.Close()
-
 		var coins []CoinInfo
-		json.NewDecoder(resp.Body).Decode(&coins)
+		c.getJSON("/api/coins", &coins)
 		return coinsMsg(coins)
 	}
 }
 
-func fetchHistory() tea.Cmd {
+// fetchRecent gets the active symbol's last prices from the API's memory,
+// to draw the sparkline before live ones come in. Any failure (say a server
+// without /api/recent) just leaves the sparkline to fill up live.
+func fetchRecent(c *apiClient) tea.Cmd {
 	return func() tea.Msg {
-		resp, err := http.Get(serverURL + "/api/history")
-		if err != nil {
-			return historyMsg(nil)
+		var recent RecentResponse
+		if err := c.getJSON(fmt.Sprintf("/api/recent?n=%d", maxHistory), &recent); err != nil {
+			return recentMsg{}
 		}
-		defer resp.Body.Close()
+		return recentMsg(recent)
+	}
+}
 
-		var trades []HistoryTrade
-		json.NewDecoder(resp.Body).Decode(&trades)
-		return historyMsg(trades)
+func fetchHistory(c *apiClient) tea.Cmd {
+	return func() tea.Msg {
+		trades := []HistoryTrade{}
+		err := c.getJSON("/api/history", &trades)
+		return historyMsg{trades: trades, err: err}
 	}
 }
 
-func changeSymbol(symbol string) tea.Cmd {
+func changeSymbol(c *apiClient, symbol string) tea.Cmd {
 	return func() tea.Msg {
-		body, _ := json.Marshal(map[string]string{"symbol": symbol})
-		resp, err := http.Post(serverURL+"/api/symbol", "application/json", bytes.NewReader(body))
-		if err != nil {
-			return nil
-		}
-		resp.Body.Close()
-		return symbolChangedMsg{}
+		err := c.postJSON("/api/symbol", map[string]string{"symbol": symbol}, nil)
+		return symbolChangedMsg{err: err}
 	}
 }
 
@@ -252,12 +445,32 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				// Switch to coin selection
 				m.mode = coinSelectView
 				m.coinCursor = 0
-				return m, fetchCoins()
+				return m, fetchCoins(m.api)
 			case "h":
 				// Switch to history view
 				m.mode = historyView
 				m.historyScroll = 0
-				return m, fetchHistory()
+				m.dbHistory, m.historyErr = nil, ""
+				return m, fetchHistory(m.api)
+			case "p":
+				// Freeze the display; resume fetching on the next press
+				m.paused = !m.paused
+				if m.paused {
+					return m, nil
+				}
+				return m, tea.Batch(fetchData(m.api), m.restartTick())
+			case "s":
+				// Toggle sparkline smoothing (display only)
+				m.smooth = !m.smooth
+				return m, nil
+			case "+", "=":
+				// Slower refresh
+				m.interval = stepInterval(m.interval, 1)
+				return m, m.restartTick()
+			case "-", "_":
+				// Faster refresh
+				m.interval = stepInterval(m.interval, -1)
+				return m, m.restartTick()
 			}
 
 		case coinSelectView:
@@ -265,7 +478,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "ctrl+c", "q", "esc":
 				// Go back to dashboard
 				m.mode = dashboardView
-				return m, nil
+				return m, tea.Batch(fetchData(m.api), fetchRecent(m.api), m.restartTick())
 			case "up", "k":
 				if m.coinCursor > 0 {
 					m.coinCursor--
@@ -277,8 +490,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "enter", " ":
 				if len(m.coins) > 0 {
 					m.switching = true
+					m.coinErr = ""
 					selectedCoin := m.coins[m.coinCursor]
-					return m, changeSymbol(selectedCoin.Symbol)
+					return m, changeSymbol(m.api, selectedCoin.Symbol)
 				}
 			}
 
@@ -287,7 +501,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "ctrl+c", "q", "esc":
 				// Go back to dashboard
 				m.mode = dashboardView
-				return m, tea.Batch(fetchData(), tick())
+				return m, tea.Batch(fetchData(m.api), m.restartTick())
 			case "up", "k":
 				if m.historyScroll > 0 {
 					m.historyScroll--
@@ -302,39 +516,54 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			case "r":
 				// Refresh history
-				return m, fetchHistory()
+				m.historyErr = ""
+				return m, fetchHistory(m.api)
 			}
 		}
 
 	case tickMsg:
+		// Stale loop, or paused: let the loop die until resumed
+		if msg.gen != m.tickGen || m.paused {
+			return m, nil
+		}
 		if m.mode == dashboardView && !m.switching {
-			return m, tea.Batch(fetchData(), tick())
+			return m, tea.Batch(fetchData(m.api), tick(m.pollDelay(), m.tickGen))
 		}
-		return m, tick()
+		return m, tick(m.pollDelay(), m.tickGen)
 
 	case dataMsg:
+		// Drop fetches that were in flight when we paused
+		if m.paused {
+			return m, nil
+		}
 		newData := DashboardData(msg)
 
-		// Check if symbol changed (reset history)
-		if m.data.Symbol != "" && m.data.Symbol != newData.Symbol {
-			m.history = make([]float64, 0, 20)
+		// Server unreachable: keep the coin in the header but drop the
+		// price so nothing looks live, and let the tick loop back off
+		if newData.Error != "" {
+			m.failures++
+			m.lastError = newData.Error
+			if newData.Error == errServerDown && m.data.Symbol != "" {
+				m.lastError = errServerStopped
+			}
+			m.data.Price = 0
+			m.data.Change = 0
+			m.data.ChangePercent = 0
+			m.data.HasBook = false
+			return m, nil
 		}
+		m.failures = 0
+		m.lastError = ""
+		newData = keepStale(m.data, newData)
 
-		// Calculate change
-		if m.data.Price > 0 && newData.Price > 0 && m.data.Symbol == newData.Symbol {
-			newData.Change = newData.Price - m.data.Price
-			newData.ChangePercent = (newData.Change / m.data.Price) * 100
+		// Check if symbol changed (reset history)
+		if m.data.Symbol != "" && m.data.Symbol != newData.Symbol {
+			m.history = make([]float64, 0, maxHistory)
 		}
-		newData.PrevPrice = m.data.Price
-
-		m.data = newData
 
-		// Update history
-		if newData.Price > 0 {
-			m.history = append(m.history, newData.Price)
-			if len(m.history) > 20 {
-				m.history = m.history[1:]
-			}
+		m.data = applyChange(m.data, newData)
+		if !m.data.PriceStale {
+			m.history = appendHistory(m.history, m.data.Price, maxHistory)
 		}
 		return m, nil
 
@@ -349,15 +578,31 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case recentMsg:
+		m.history = seedHistory(m.history, m.data.Symbol, RecentResponse(msg))
+		return m, nil
+
 	case historyMsg:
-		m.dbHistory = msg
+		if msg.err != nil {
+			m.historyErr = errorMessage(msg.err)
+			return m, nil
+		}
+		m.dbHistory = msg.trades
 		return m, nil
 
 	case symbolChangedMsg:
 		m.switching = false
+		// A refused switch (say an unknown symbol) goes back to the list to
+		// say why. Unreachable servers fall through: the dashboard shows
+		// the reconnect state.
+		if msg.err != nil && errors.As(msg.err, new(*APIError)) {
+			m.mode = coinSelectView
+			m.coinErr = errorMessage(msg.err)
+			return m, nil
+		}
 		m.mode = dashboardView
-		m.history = make([]float64, 0, 20)
-		return m, tea.Batch(fetchData(), tick())
+		m.history = make([]float64, 0, maxHistory)
+		return m, tea.Batch(fetchData(m.api), fetchRecent(m.api), m.restartTick())
 	}
 
 	return m, nil
@@ -400,6 +645,9 @@ func (m model) viewCoinSelect() string {
 		}
 	}
 
+	if m.coinErr != "" {
+		s += "\n" + errorStyle.Render(m.coinErr) + "\n"
+	}
 	s += helpStyle.Render("\n↑/↓: navigate • enter: select • esc: cancel")
 
 	return boxStyle.Render(s)
@@ -413,9 +661,14 @@ func (m model) viewHistory() string {
 
 	s := headerStyle.Render(fmt.Sprintf("◆ %s Trade History (from TimescaleDB)", coinName)) + "\n\n"
 
-	if len(m.dbHistory) == 0 {
+	switch {
+	case m.historyErr != "":
+		s += errorStyle.Render(m.historyErr)
+	case m.dbHistory == nil:
 		s += labelStyle.Render("Loading history...")
-	} else {
+	case len(m.dbHistory) == 0:
+		s += labelStyle.Render("No trades stored yet")
+	default:
 		// Show header
 		s += fmt.Sprintf("%s  %s  %s\n",
 			labelStyle.Render("Time"),
@@ -431,11 +684,8 @@ func (m model) viewHistory() string {
 
 		for i := m.historyScroll; i < endIdx; i++ {
 			trade := m.dbHistory[i]
-			timeStr := trade.Timestamp.Local().Format("15:04:05")
-			priceStr := fmt.Sprintf("$%.2f", trade.Price)
-			if trade.Price < 1 {
-				priceStr = fmt.Sprintf("$%.6f", trade.Price)
-			}
+			timeStr := trade.Timestamp.In(displayLoc).Format("15:04:05")
+			priceStr := formatAmount(trade.Price, displayPrecision(trade.Price, m.data.Precision), m.data.Quote)
 
 			s += fmt.Sprintf("%s  %s  %s\n",
 				timeStyle.Render(timeStr),
@@ -454,12 +704,13 @@ func (m model) viewHistory() string {
 }
 
 func (m model) viewDashboard() string {
-	// Error state
-	if m.data.Error != "" {
+	// Never reached the server yet
+	if m.failures > 0 && m.data.Symbol == "" {
 		content := fmt.Sprintf(
-			"%s\n\n%s\n\n%s",
+			"%s\n\n%s\n%s\n\n%s",
 			headerStyle.Render("◆ Trading Pipeline Dashboard"),
-			errorStyle.Render(m.data.Error),
+			errorStyle.Render(m.lastError),
+			labelStyle.Render(fmt.Sprintf("Reconnecting in %s (attempt %d)...", m.pollDelay(), m.failures)),
 			helpStyle.Render("Press 'q' to quit"),
 		)
 		return boxStyle.Render(content)
@@ -492,41 +743,64 @@ func (m model) viewDashboard() string {
 	if coinName == "" {
 		coinName = "Crypto"
 	}
-	header := headerStyle.Render(fmt.Sprintf("◆ %s Real-Time Dashboard", coinName))
+	title := fmt.Sprintf("◆ %s Real-Time Dashboard", coinName) + staleMark(m.data.SymbolStale)
+	if m.failures > 0 && m.lastError == errServerStopped {
+		title += " " + errorStyle.Render("⟳ server stopped, reconnecting…")
+	} else if m.failures > 0 {
+		title += " " + errorStyle.Render("⟳ reconnecting…")
+	}
+	if m.paused {
+		title += " " + pausedStyle.Render("PAUSED")
+	}
+	header := headerStyle.Render(title)
 
 	// Price display
-	priceStr := fmt.Sprintf("$%.2f", m.data.Price)
-	if m.data.Price < 1 {
-		priceStr = fmt.Sprintf("$%.6f", m.data.Price)
+	// Everything is shown at the coin's precision so values line up
+	prec := displayPrecision(m.data.Price, m.data.Precision)
+
+	priceStr := formatAmount(m.data.Price, prec, m.data.Quote)
+	if m.failures > 0 {
+		priceStr = "—"
 	}
 
 	// Change indicator
 	var changeStr string
 	if m.data.Change > 0 {
-		changeStr = upStyle.Render(fmt.Sprintf("▲ +%.2f (+%.4f%%)", m.data.Change, m.data.ChangePercent))
+		changeStr = upStyle.Render(fmt.Sprintf("▲ +%s (+%.4f%%)", formatNumber(m.data.Change, prec), m.data.ChangePercent))
 	} else if m.data.Change < 0 {
-		changeStr = downStyle.Render(fmt.Sprintf("▼ %.2f (%.4f%%)", m.data.Change, m.data.ChangePercent))
+		changeStr = downStyle.Render(fmt.Sprintf("▼ %s (%.4f%%)", formatNumber(m.data.Change, prec), m.data.ChangePercent))
 	} else {
 		changeStr = labelStyle.Render("━ 0.00 (0.00%)")
 	}
 
-	priceDisplay := priceStyle.Render(priceStr) + "  " + changeStr
+	priceDisplay := qualityStyle(m.data).Render(priceStr) + staleMark(m.data.PriceStale) + "  " + changeStr
+	if m.data.HasBook {
+		priceDisplay += "  " + labelStyle.Render("bid/ask spread "+formatAmount(m.data.BookSpread, prec, m.data.Quote))
+	}
 
 	// Stats
+	stale := staleMark(m.data.StatsStale)
 	stats := fmt.Sprintf(
-		"%s %s\n%s %s\n%s %s\n%s %s",
+		"%s %s%s\n%s %s%s\n%s %s%s\n%s %s%s\n%s %s – %s%s",
 		labelStyle.Render("Moving Avg:"),
-		valueStyle.Render(fmt.Sprintf("$%.2f", m.data.MovingAverage)),
+		valueStyle.Render(formatAmount(m.data.MovingAverage, prec, m.data.Quote)), stale,
 		labelStyle.Render("Session High:"),
-		upStyle.Render(fmt.Sprintf("$%.2f", m.data.High)),
+		upStyle.Render(formatAmount(m.data.High, prec, m.data.Quote)), stale,
 		labelStyle.Render("Session Low:"),
-		downStyle.Render(fmt.Sprintf("$%.2f", m.data.Low)),
+		downStyle.Render(formatAmount(m.data.Low, prec, m.data.Quote)), stale,
 		labelStyle.Render("Spread:"),
-		valueStyle.Render(fmt.Sprintf("$%.2f", m.data.High-m.data.Low)),
+		valueStyle.Render(formatAmount(m.data.High-m.data.Low, prec, m.data.Quote)), stale,
+		labelStyle.Render("Recent Range:"),
+		downStyle.Render(formatAmount(m.data.RollingLow, prec, m.data.Quote)),
+		upStyle.Render(formatAmount(m.data.RollingHigh, prec, m.data.Quote)), stale,
 	)
 
 	// Sparkline
 	sparkline := m.renderSparkline()
+	sparkLabel := "Price History: "
+	if m.smooth {
+		sparkLabel = "Price History (smoothed): "
+	}
 
 	// Combine
 	content := fmt.Sprintf(
@@ -534,9 +808,9 @@ func (m model) viewDashboard() string {
 		header,
 		priceDisplay,
 		stats,
-		labelStyle.Render("Price History: "),
+		labelStyle.Render(sparkLabel),
 		sparkline,
-		helpStyle.Render("'c': change coin • 'h': view DB history • 'q': quit"),
+		helpStyle.Render(fmt.Sprintf("'c': change coin • 'h': view DB history • 'p': pause • 's': smooth • '+/-': refresh (%s) • 'q': quit", m.interval)),
 	)
 
 	return boxStyle.Render(content)
@@ -547,8 +821,14 @@ func (m model) renderSparkline() string {
 		return labelStyle.Render("waiting for data...")
 	}
 
-	min, max := m.history[0], m.history[0]
-	for _, v := range m.history {
+	// Colors follow the series as drawn, so smoothing also calms them
+	series := m.history
+	if m.smooth {
+		series = smoothEMA(m.history, sparkAlpha)
+	}
+
+	min, max := series[0], series[0]
+	for _, v := range series {
 		if v < min {
 			min = v
 		}
@@ -557,7 +837,7 @@ func (m model) renderSparkline() string {
 		}
 	}
 
-	chars := []rune{'▁', '▂', '▃', '▄', '▅', '▆', '▇', '█'}
+	chars := sparkChars
 
 	var spark string
 	rang := max - min
@@ -565,7 +845,7 @@ func (m model) renderSparkline() string {
 		rang = 1
 	}
 
-	for i, v := range m.history {
+	for i, v := range series {
 		normalized := (v - min) / rang
 		idx := int(normalized * float64(len(chars)-1))
 		if idx >= len(chars) {
@@ -573,9 +853,9 @@ func (m model) renderSparkline() string {
 		}
 
 		char := string(chars[idx])
-		if i > 0 && v > m.history[i-1] {
+		if i > 0 && v > series[i-1] {
 			spark += upStyle.Render(char)
-		} else if i > 0 && v < m.history[i-1] {
+		} else if i > 0 && v < series[i-1] {
 			spark += downStyle.Render(char)
 		} else {
 			spark += valueStyle.Render(char)
@@ -586,7 +866,65 @@ func (m model) renderSparkline() string {
 }
 
 func main() {
-	p := tea.NewProgram(initialModel(), tea.WithAltScreen())
+	defaultServer := os.Getenv("SERVER_URL")
+	if defaultServer == "" {
+		defaultServer = defaultServerURL
+	}
+
+	server := flag.String("server", defaultServer, "API server base URL (or set SERVER_URL)")
+	basePath := flag.String("base-path", os.Getenv("BASE_PATH"), "path prefix the API is mounted under, e.g. /trading (or set BASE_PATH)")
+	interval := flag.Duration("interval", 500*time.Millisecond, "dashboard refresh interval (100ms-5s)")
+	noAltScreen := flag.Bool("no-altscreen", false, "render inline instead of taking over the terminal")
+	plain := flag.Bool("plain", !isTerminal(os.Stdout), "print a single unstyled status line (default when stdout isn't a terminal)")
+	once := flag.Bool("once", false, "print one plain snapshot and exit")
+	timezone := flag.String("timezone", os.Getenv("TIMEZONE"), "IANA zone to show times in, e.g. America/New_York (or set TIMEZONE; default local)")
+	theme := flag.String("theme", defaultTheme(), "color palette: "+choices(palettes)+" (mono when NO_COLOR is set)")
+	spark := flag.String("spark", "block", "sparkline glyphs: "+choices(sparkGlyphs))
+	flag.Parse()
+
+	pal, ok := palettes[*theme]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: invalid -theme %q (%s)\n", *theme, choices(palettes))
+		os.Exit(1)
+	}
+	applyPalette(pal)
+	if sparkChars, ok = sparkGlyphs[*spark]; !ok {
+		fmt.Fprintf(os.Stderr, "Error: invalid -spark %q (%s)\n", *spark, choices(sparkGlyphs))
+		os.Exit(1)
+	}
+
+	if *timezone != "" {
+		loc, err := time.LoadLocation(*timezone)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -timezone %q: %v\n", *timezone, err)
+			os.Exit(1)
+		}
+		displayLoc = loc
+	}
+
+	if *interval < minInterval {
+		*interval = minInterval
+	}
+	if *interval > maxInterval {
+		*interval = maxInterval
+	}
+
+	api := newAPIClient(strings.TrimRight(*server, "/")+"/"+strings.Trim(*basePath, "/"), nil)
+
+	if *plain || *once {
+		if err := runPlain(api, *interval, *once); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var opts []tea.ProgramOption
+	if !*noAltScreen {
+		opts = append(opts, tea.WithAltScreen())
+	}
+
+	p := tea.NewProgram(initialModel(api, *interval), opts...)
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
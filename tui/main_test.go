@@ -0,0 +1,211 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// response is what the fake API sends for one path
+type response struct {
+	status int
+	body   string
+}
+
+// fakeAPI serves routes, and 404 for any other path
+func fakeAPI(t *testing.T, routes map[string]response) *apiClient {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, ok := routes[r.URL.Path]
+		if !ok {
+			resp = response{http.StatusNotFound, `{"error":"Not found"}`}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(resp.status)
+		w.Write([]byte(resp.body))
+	}))
+	t.Cleanup(srv.Close)
+	return newAPIClient(srv.URL, srv.Client())
+}
+
+var (
+	symbolOK = response{http.StatusOK, `{"symbol":"ethusdt","name":"Ethereum (ETH)","quote":"usdt","precision":2}`}
+	priceOK  = response{http.StatusOK, `{"price":2000.5,"quality":0.9}`}
+	statsOK  = response{http.StatusOK, `{"moving_average":1990,"high":2100,"low":1900,"rolling_high":2050,"rolling_low":1950}`}
+	noData   = response{http.StatusNotFound, `{"error":"No data for ethusdt yet","code":"no_data"}`}
+	failure  = response{http.StatusInternalServerError, `{"error":"boom"}`}
+)
+
+func TestFetchData(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes map[string]response
+		want   DashboardData
+	}{
+		{
+			name: "everything",
+			routes: map[string]response{
+				"/api/symbol": symbolOK,
+				"/api/price":  priceOK,
+				"/api/stats":  statsOK,
+				"/api/book":   {http.StatusOK, `{"bid":2000.4,"ask":2000.6,"spread":0.2}`},
+			},
+			want: DashboardData{
+				Symbol: "ethusdt", CoinName: "Ethereum (ETH)", Quote: "usdt", Precision: 2,
+				Price: 2000.5, Quality: 0.9, HasQuality: true,
+				MovingAverage: 1990, High: 2100, Low: 1900, RollingHigh: 2050, RollingLow: 1950,
+				BookSpread: 0.2, HasBook: true,
+				Connected: true,
+			},
+		},
+		{
+			name: "no book and no quality",
+			routes: map[string]response{
+				"/api/symbol": symbolOK,
+				"/api/price":  {http.StatusOK, `{"price":2000.5}`},
+				"/api/stats":  statsOK,
+			},
+			want: DashboardData{
+				Symbol: "ethusdt", CoinName: "Ethereum (ETH)", Quote: "usdt", Precision: 2,
+				Price:         2000.5,
+				MovingAverage: 1990, High: 2100, Low: 1900, RollingHigh: 2050, RollingLow: 1950,
+				Connected: true,
+			},
+		},
+		{
+			name: "no trades yet",
+			routes: map[string]response{
+				"/api/symbol": symbolOK,
+				"/api/price":  noData,
+				"/api/stats":  noData,
+			},
+			want: DashboardData{
+				Symbol: "ethusdt", CoinName: "Ethereum (ETH)", Quote: "usdt", Precision: 2,
+				Connected: true,
+			},
+		},
+		{
+			name: "stats failing",
+			routes: map[string]response{
+				"/api/symbol": symbolOK,
+				"/api/price":  priceOK,
+				"/api/stats":  failure,
+			},
+			want: DashboardData{
+				Symbol: "ethusdt", CoinName: "Ethereum (ETH)", Quote: "usdt", Precision: 2,
+				Price: 2000.5, Quality: 0.9, HasQuality: true,
+				StatsStale: true,
+				Connected:  true,
+			},
+		},
+		{
+			name: "everything failing",
+			routes: map[string]response{
+				"/api/symbol": failure,
+				"/api/price":  failure,
+				"/api/stats":  failure,
+			},
+			want: DashboardData{
+				SymbolStale: true, PriceStale: true, StatsStale: true,
+				Error: "Server error: boom",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fetchData(fakeAPI(t, tt.routes))()
+			if !reflect.DeepEqual(got, dataMsg(tt.want)) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchDataServerDown(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	got := fetchData(newAPIClient(url, nil))()
+	if want := dataMsg(DashboardData{Error: errServerDown}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestApplyChange(t *testing.T) {
+	tests := []struct {
+		name          string
+		prev, next    DashboardData
+		change, pct   float64
+		wantPrevPrice float64
+	}{
+		{
+			name:   "rise",
+			prev:   DashboardData{Symbol: "btcusdt", Price: 100},
+			next:   DashboardData{Symbol: "btcusdt", Price: 110},
+			change: 10, pct: 10, wantPrevPrice: 100,
+		},
+		{
+			name:   "fall",
+			prev:   DashboardData{Symbol: "btcusdt", Price: 200},
+			next:   DashboardData{Symbol: "btcusdt", Price: 150},
+			change: -50, pct: -25, wantPrevPrice: 200,
+		},
+		{
+			name:          "first price",
+			prev:          DashboardData{Symbol: "btcusdt"},
+			next:          DashboardData{Symbol: "btcusdt", Price: 110},
+			wantPrevPrice: 0,
+		},
+		{
+			name:          "no price yet",
+			prev:          DashboardData{Symbol: "btcusdt", Price: 100},
+			next:          DashboardData{Symbol: "btcusdt"},
+			wantPrevPrice: 100,
+		},
+		{
+			name:          "symbol switched",
+			prev:          DashboardData{Symbol: "btcusdt", Price: 42000},
+			next:          DashboardData{Symbol: "ethusdt", Price: 2000},
+			wantPrevPrice: 42000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := applyChange(tt.prev, tt.next)
+			if got.Change != tt.change || got.ChangePercent != tt.pct || got.PrevPrice != tt.wantPrevPrice {
+				t.Errorf("change %v (%v%%) prev %v, want %v (%v%%) prev %v",
+					got.Change, got.ChangePercent, got.PrevPrice, tt.change, tt.pct, tt.wantPrevPrice)
+			}
+		})
+	}
+}
+
+func TestAppendHistory(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []float64
+		price   float64
+		max     int
+		want    []float64
+	}{
+		{"append", []float64{1, 2}, 3, 5, []float64{1, 2, 3}},
+		{"fills up", []float64{1, 2}, 3, 3, []float64{1, 2, 3}},
+		{"drops the oldest", []float64{1, 2, 3}, 4, 3, []float64{2, 3, 4}},
+		{"shrinks to max", []float64{1, 2, 3, 4, 5}, 6, 2, []float64{5, 6}},
+		{"ignores zero", []float64{1, 2}, 0, 5, []float64{1, 2}},
+		{"ignores negative", []float64{1, 2}, -1, 5, []float64{1, 2}},
+		{"empty", nil, 7, 5, []float64{7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := appendHistory(tt.history, tt.price, tt.max); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// isTerminal reports whether f is attached to a character device
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// runPlain prints the dashboard as a single unstyled line per refresh. On
+// a terminal the line is redrawn in place; otherwise each refresh is a new
+// line so output can be logged or piped. With once, it prints one snapshot.
+func runPlain(api *apiClient, interval time.Duration, once bool) error {
+	tty := isTerminal(os.Stdout)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	defer signal.Stop(stop)
+
+	var prev DashboardData
+	for {
+		data := DashboardData(fetchData(api)().(dataMsg))
+
+		if once {
+			if data.Error != "" {
+				return fmt.Errorf("%s", data.Error)
+			}
+			fmt.Println(plainLine(data))
+			return nil
+		}
+
+		var line string
+		if data.Error != "" {
+			line = "reconnecting: " + data.Error
+		} else {
+			data = applyChange(prev, keepStale(prev, data))
+			prev = data
+			line = plainLine(data)
+		}
+
+		if tty {
+			fmt.Print("\r\033[K" + line)
+		} else {
+			fmt.Println(line)
+		}
+
+		select {
+		case <-stop:
+			if tty {
+				fmt.Println()
+			}
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// plainLine renders one dashboard snapshot without styling
+func plainLine(d DashboardData) string {
+	prec := displayPrecision(d.Price, d.Precision)
+
+	change := "0"
+	if d.Change != 0 {
+		change = fmt.Sprintf("%+.4f%%", d.ChangePercent)
+	}
+
+	// Values kept from an earlier fetch are marked like the dashboard's
+	mark := func(s string, stale bool) string {
+		if stale {
+			return s + " (stale)"
+		}
+		return s
+	}
+
+	parts := []string{
+		time.Now().In(displayLoc).Format("15:04:05"),
+		mark(strings.ToUpper(d.Symbol), d.SymbolStale),
+		mark(formatAmount(d.Price, prec, d.Quote), d.PriceStale),
+		change,
+		mark("ma "+formatAmount(d.MovingAverage, prec, d.Quote), d.StatsStale),
+		mark("high "+formatAmount(d.High, prec, d.Quote), d.StatsStale),
+		mark("low "+formatAmount(d.Low, prec, d.Quote), d.StatsStale),
+	}
+	if d.HasBook {
+		parts = append(parts, "spread "+formatAmount(d.BookSpread, prec, d.Quote))
+	}
+	if d.HasQuality {
+		parts = append(parts, fmt.Sprintf("quality %.2f", d.Quality))
+	}
+	return strings.Join(parts, "  ")
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// palette is the dashboard's colors (-theme)
+type palette struct {
+	accent lipgloss.TerminalColor // border, headers, selection
+	up     lipgloss.TerminalColor // price rises and highs
+	down   lipgloss.TerminalColor // price falls, lows and errors
+	text   lipgloss.TerminalColor
+	muted  lipgloss.TerminalColor // labels and help
+	time   lipgloss.TerminalColor
+	warn   lipgloss.TerminalColor // the PAUSED badge
+}
+
+// palettes are the -theme choices. colorblind swaps green/red for the
+// Okabe-Ito blue and orange, which stay apart under the common kinds of
+// color blindness; mono has no colors at all, and is the default when
+// NO_COLOR is set.
+var palettes = map[string]palette{
+	"default": {
+		accent: lipgloss.Color("10"),
+		up:     lipgloss.Color("10"),
+		down:   lipgloss.Color("9"),
+		text:   lipgloss.Color("15"),
+		muted:  lipgloss.Color("8"),
+		time:   lipgloss.Color("6"),
+		warn:   lipgloss.Color("11"),
+	},
+	"colorblind": {
+		accent: lipgloss.Color("#56B4E9"),
+		up:     lipgloss.Color("#56B4E9"),
+		down:   lipgloss.Color("#E69F00"),
+		text:   lipgloss.Color("15"),
+		muted:  lipgloss.Color("8"),
+		time:   lipgloss.Color("#F0E442"),
+		warn:   lipgloss.Color("#F0E442"),
+	},
+	"mono": {
+		accent: lipgloss.NoColor{},
+		up:     lipgloss.NoColor{},
+		down:   lipgloss.NoColor{},
+		text:   lipgloss.NoColor{},
+		muted:  lipgloss.NoColor{},
+		time:   lipgloss.NoColor{},
+		warn:   lipgloss.NoColor{},
+	},
+}
+
+// sparkGlyphs are the -spark choices, lowest to highest. braille has
+// fewer levels but suits fonts whose block elements don't line up; ascii
+// works anywhere.
+var sparkGlyphs = map[string][]rune{
+	"block":   []rune("▁▂▃▄▅▆▇█"),
+	"braille": []rune("⣀⣤⣶⣿"),
+	"ascii":   []rune("_.:-=+*#"),
+}
+
+// sparkChars are the glyphs the sparkline is drawn with
+var sparkChars = sparkGlyphs["block"]
+
+// defaultTheme is mono when NO_COLOR (https://no-color.org) is set
+func defaultTheme() string {
+	if os.Getenv("NO_COLOR") != "" {
+		return "mono"
+	}
+	return "default"
+}
+
+// choices lists a flag's valid values for its usage and errors
+func choices[T any](m map[string]T) string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// Styles
+var (
+	boxStyle, priceStyle, upStyle, downStyle, labelStyle, valueStyle,
+	headerStyle, helpStyle, errorStyle, selectedStyle, itemStyle,
+	timeStyle, pausedStyle, priceWarnStyle, priceLowStyle lipgloss.Style
+)
+
+func init() {
+	applyPalette(palettes["default"])
+}
+
+// applyPalette rebuilds the styles from p
+func applyPalette(p palette) {
+	boxStyle = lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(p.accent).
+		Padding(1, 2)
+
+	priceStyle = lipgloss.NewStyle().
+		Bold(true).
+		Foreground(p.text)
+
+	upStyle = lipgloss.NewStyle().
+		Foreground(p.up)
+
+	downStyle = lipgloss.NewStyle().
+		Foreground(p.down)
+
+	labelStyle = lipgloss.NewStyle().
+		Foreground(p.muted)
+
+	valueStyle = lipgloss.NewStyle().
+		Foreground(p.text)
+
+	headerStyle = lipgloss.NewStyle().
+		Bold(true).
+		Foreground(p.accent).
+		MarginBottom(1)
+
+	helpStyle = lipgloss.NewStyle().
+		Foreground(p.muted).
+		MarginTop(1)
+
+	errorStyle = lipgloss.NewStyle().
+		Foreground(p.down)
+
+	selectedStyle = lipgloss.NewStyle().
+		Foreground(p.accent).
+		Bold(true)
+
+	itemStyle = lipgloss.NewStyle().
+		Foreground(p.muted)
+
+	timeStyle = lipgloss.NewStyle().
+		Foreground(p.time)
+
+	pausedStyle = lipgloss.NewStyle().
+		Bold(true).
+		Foreground(p.warn)
+
+	priceWarnStyle = priceStyle.Foreground(p.warn)
+	priceLowStyle = priceStyle.Foreground(p.down)
+}
+
+// qualityStyle colors the price by the API's data-quality score: as usual
+// from 0.8, in the warning color from 0.5, and in the down color below
+// that (stale, just after a gap, or still warming up)
+func qualityStyle(d DashboardData) lipgloss.Style {
+	switch {
+	case !d.HasQuality || d.Quality >= 0.8:
+		return priceStyle
+	case d.Quality >= 0.5:
+		return priceWarnStyle
+	default:
+		return priceLowStyle
+	}
+}
+
+// staleMark follows a value kept from an earlier fetch because its
+// endpoint failed this time
+func staleMark(stale bool) string {
+	if !stale {
+		return ""
+	}
+	return " " + labelStyle.Render("(stale)")
+}